@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/go-mangos/mangos"
+)
+
+// allowed holds the networks that may connect to a listening node. An empty list accepts every peer.
+var allowed []*net.IPNet
+
+// maxPeers limits the number of peers a listening node accepts at the same time. Zero means no limit.
+var maxPeers int
+
+// portHooks combines several port hooks into one, as a socket can only have a single hook. A new connection is accepted only if all hooks accept it; the first hook that says no stops the chain.
+func portHooks(hooks ...mangos.PortHook) mangos.PortHook {
+	return func(action mangos.PortAction, port mangos.Port) bool {
+		for _, hook := range hooks {
+			if !hook(action, port) && action == mangos.PortActionAdd {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// parseAllowList turns a comma-separated list of IP addresses and CIDR ranges into a list of networks. A plain address is treated as a network containing only this address.
+func parseAllowList(list string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid address '%s'", entry)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				bits = 8 * net.IPv4len
+			}
+			entry = fmt.Sprintf("%s/%d", entry, bits)
+		}
+		_, n, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// allowPeer is a port hook that rejects incoming connections from addresses outside the allow list. Mangos closes the new connection right away if a hook returns false for PortActionAdd.
+//
+// Only connections accepted by a listener are checked; a dialing node chose its peer already. Transports without an IP address (like ipc) are always accepted.
+func (n *Node) allowPeer(action mangos.PortAction, port mangos.Port) bool {
+	if action != mangos.PortActionAdd || !port.IsServer() || len(allowed) == 0 {
+		return true
+	}
+	prop, err := port.GetProp(mangos.PropRemoteAddr)
+	if err != nil {
+		return true
+	}
+	addr, ok := prop.(net.Addr)
+	if !ok {
+		return true
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return true
+	}
+	ip := net.ParseIP(host)
+	for _, n := range allowed {
+		if ip != nil && n.Contains(ip) {
+			return true
+		}
+	}
+	logError("Node %s rejects connection from %s: address not in allow list\n", n.Name, addr)
+	return false
+}
+
+// limitPeers is a port hook that rejects incoming connections once the listening node has reached maxPeers. It must run after all other hooks that may reject a connection, or else connections that a later hook rejects would still be counted.
+func (n *Node) limitPeers(action mangos.PortAction, port mangos.Port) bool {
+	if !port.IsServer() {
+		return true
+	}
+	n.peers.Lock()
+	defer n.peers.Unlock()
+	switch action {
+	case mangos.PortActionAdd:
+		if maxPeers > 0 && n.peers.n >= maxPeers {
+			logError("Node %s rejects connection from %s: limit of %d peers reached\n", n.Name, remoteAddr(port), maxPeers)
+			return false
+		}
+		n.peers.n++
+	case mangos.PortActionRemove:
+		n.peers.n--
+	}
+	return true
+}
+
+// remoteAddr returns the address of the peer at the other end of port, or the port's URL if the transport does not know the peer's address.
+func remoteAddr(port mangos.Port) string {
+	if prop, err := port.GetProp(mangos.PropRemoteAddr); err == nil {
+		if addr, ok := prop.(net.Addr); ok {
+			return addr.String()
+		}
+	}
+	return port.Address()
+}
+
+// logConnections turns on logging of connects and disconnects.
+var logConnections bool
+
+// logConnection is a port hook that logs when a peer connects or disconnects. Connections are otherwise invisible, which makes it hard to tell why a message did not arrive, especially in topologies with many peers like BUS or PubSub. It never rejects a connection, and it runs after the hooks that may do so, so it only logs connections that were actually accepted.
+func (n *Node) logConnection(action mangos.PortAction, port mangos.Port) bool {
+	if !logConnections {
+		return true
+	}
+	role := "dialed"
+	if port.IsServer() {
+		role = "accepted"
+	}
+	switch action {
+	case mangos.PortActionAdd:
+		logEvent(Event{Node: n.Name, Event: "connect", Peer: remoteAddr(port), Msg: role + " on " + port.Address()}, "Node %s: Connected to %s (%s on %s)\n", n.Name, remoteAddr(port), role, port.Address())
+	case mangos.PortActionRemove:
+		logEvent(Event{Node: n.Name, Event: "disconnect", Peer: remoteAddr(port), Msg: role + " on " + port.Address()}, "Node %s: Disconnected from %s (%s on %s)\n", n.Name, remoteAddr(port), role, port.Address())
+	}
+	return true
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// "Collect output from multiple nodes" is what a pull node does when several push nodes feed it: the fan-in side of a pipeline. To turn the arrival of items into something measurable, the pull node runs every item through an aggregator, which numbers the items in the order of arrival and keeps a few running statistics. When the pull node is done, it logs a summary.
+//
+// For a fan-in, the pull node listens and the push nodes dial:
+//
+//	./messaging -listen pull tcp://localhost:54545
+//	./messaging -dial push tcp://localhost:54545
+//	./messaging -dial push tcp://localhost:54545
+
+// aggregator collects the statistics of the items that a pull node receives.
+type aggregator struct {
+	count    int
+	first    time.Time
+	last     time.Time
+	bySender map[string]int
+}
+
+// newAggregator creates an empty aggregator.
+func newAggregator() *aggregator {
+	return &aggregator{bySender: map[string]int{}}
+}
+
+// add counts m and returns its position in the order of arrival, starting at 1.
+func (a *aggregator) add(m Message) int {
+	now := time.Now()
+	if a.count == 0 {
+		a.first = now
+	}
+	a.last = now
+	a.count++
+	a.bySender[m.From]++
+	return a.count
+}
+
+// rate returns the number of items per second between the first and the last item. With fewer than two items, there is no rate to speak of.
+func (a *aggregator) rate() float64 {
+	elapsed := a.last.Sub(a.first).Seconds()
+	if a.count < 2 || elapsed <= 0 {
+		return 0
+	}
+	return float64(a.count-1) / elapsed
+}
+
+// String summarizes the statistics, like "12 items from 2 senders at 3.9 items/s (push-a: 6, push-b: 6)".
+func (a *aggregator) String() string {
+	senders := make([]string, 0, len(a.bySender))
+	for s := range a.bySender {
+		senders = append(senders, s)
+	}
+	sort.Strings(senders)
+	counts := make([]string, len(senders))
+	for i, s := range senders {
+		counts[i] = fmt.Sprintf("%s: %d", s, a.bySender[s])
+	}
+	return fmt.Sprintf("%d items from %d senders at %.1f items/s (%s)", a.count, len(senders), a.rate(), strings.Join(counts, ", "))
+}
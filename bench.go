@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/binary"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/go-mangos/mangos"
+)
+
+// The `bench` command measures how fast messages travel over a given protocol and transport. It runs both ends in the same process, so you can compare, say, ipc against tcp against ws on your own hardware.
+//
+// With pair and reqrep, the client sends a message, waits for the server to echo it back, and measures the round trip. With pipeline, the push socket sends as fast as it can, and the pull socket measures the time each message took to arrive; this is possible because both ends share the same clock.
+//
+// Bench sends raw payloads of `-size` bytes (see `payload.go`), without the `Message` envelope, so the results reflect the transport and not the encoding.
+var (
+	benchN     = 1000
+	benchURL   = "tcp://localhost:45455"
+	benchProto = "pair"
+)
+
+// With `-burst`, the pipeline benchmark imitates a bursty producer: it sends benchBurst messages back to back, then pauses for burstPause to let the consumer catch up. The time that sending a burst takes shows whether the socket's queues are large enough for the bursts (see `-write-qlen` and `-read-qlen`): as long as a burst fits into the queues, sending it takes hardly any time; otherwise, the producer has to wait for the consumer. Compare, for example:
+//
+//	./messaging bench -proto pipeline -n 5000 -burst 1000
+//	./messaging -write-qlen 1024 -read-qlen 1024 bench -proto pipeline -n 5000 -burst 1000
+//
+// The benchmarks in `bench_test.go` do the same comparison with `go test -run - -bench Burst`.
+var benchBurst int
+
+// burstPause is the pause between two bursts.
+const burstPause = 50 * time.Millisecond
+
+// defaultBenchSize is the message size for bench if `-size` is not set.
+const defaultBenchSize = 100
+
+// benchConnectTimeout is how long bench waits for its client to connect to its server. Both run in this process, so the connection comes up at once unless something is wrong, like a port that is taken.
+const benchConnectTimeout = 5 * time.Second
+
+// benchSockets creates the server and the client socket for the benchmark protocol, and tells whether the server echoes the messages back.
+func (n *Node) benchSockets(timeout time.Duration) (server, client mangos.Socket, echo bool) {
+	switch benchProto {
+	case "pair":
+		var err error
+		server, err = n.newSocket(timeout)
+		if err == nil {
+			client, err = n.newSocket(timeout)
+		}
+		if err != nil {
+			log.Fatalf("Node %s: %s\n", n.Name, err.Error())
+		}
+		return server, client, true
+	case "reqrep":
+		return n.newRepSocket(timeout), n.newReqSocket(timeout), true
+	case "pipeline":
+		return n.newPullSocket(timeout), n.newPushSocket(), false
+	}
+	log.Fatalf("Invalid bench protocol '%s': must be pair, reqrep, or pipeline\n", benchProto)
+	return nil, nil, false
+}
+
+// runBench sends benchN messages of payloadSize bytes and reports the throughput and the latency.
+func (n *Node) runBench(timeout time.Duration) {
+	size := payloadSize
+	if size == 0 {
+		size = defaultBenchSize
+	}
+	// The pipeline benchmark needs room for the sending time.
+	if size < 8 {
+		log.Fatalf("Invalid bench message size %d: must be at least 8\n", size)
+	}
+	server, client, echo := n.benchSockets(timeout)
+	defer server.Close()
+	defer client.Close()
+	connected := onConnect(server)
+	err := listen(server, benchURL)
+	if err != nil {
+		log.Fatalf("Node %s cannot listen on socket '%s': %s\n", n.Name, benchURL, err.Error())
+	}
+	err = dial(client, benchURL)
+	if err != nil {
+		log.Fatalf("Node %s cannot dial on socket '%s': %s\n", n.Name, benchURL, err.Error())
+	}
+	select {
+	case <-connected:
+	case <-time.After(benchConnectTimeout):
+		log.Fatalf("Node %s: Client did not connect within %s\n", n.Name, benchConnectTimeout)
+	}
+
+	logInfo("Node %s: Sending %d messages of %d bytes over %s (%s)\n", n.Name, benchN, size, benchURL, benchProto)
+	payload := makePayload(size)
+	latencies := make([]time.Duration, 0, benchN)
+	// With -burst, the pipeline sender reports the average time that sending a burst took.
+	burstMean := make(chan time.Duration, 1)
+	start := time.Now()
+	if echo {
+		go func() {
+			for {
+				m, err := server.Recv()
+				if err != nil {
+					return
+				}
+				if server.Send(m) != nil {
+					return
+				}
+			}
+		}()
+		for i := 0; i < benchN; i++ {
+			sent := time.Now()
+			if err := client.Send(payload); err != nil {
+				log.Fatalf("Node %s failed to send: %s\n", n.Name, err.Error())
+			}
+			if _, err := client.Recv(); err != nil {
+				log.Fatalf("Node %s failed to receive: %s\n", n.Name, err.Error())
+			}
+			latencies = append(latencies, time.Since(sent))
+		}
+	} else {
+		// Each message carries its sending time in the first eight bytes.
+		go func() {
+			var total time.Duration
+			count := 0
+			burstStart := time.Now()
+			for i := 0; i < benchN; i++ {
+				binary.BigEndian.PutUint64(payload, uint64(time.Now().UnixNano()))
+				if client.Send(payload) != nil {
+					return
+				}
+				if benchBurst > 0 && (i+1)%benchBurst == 0 {
+					total += time.Since(burstStart)
+					count++
+					time.Sleep(burstPause)
+					burstStart = time.Now()
+				}
+			}
+			if count > 0 {
+				burstMean <- total / time.Duration(count)
+			}
+		}()
+		for i := 0; i < benchN; i++ {
+			m, err := server.Recv()
+			if err != nil {
+				log.Fatalf("Node %s failed to receive: %s\n", n.Name, err.Error())
+			}
+			sent := time.Unix(0, int64(binary.BigEndian.Uint64(m)))
+			latencies = append(latencies, time.Since(sent))
+		}
+	}
+	elapsed := time.Since(start)
+	if !echo && benchBurst > 0 && benchN >= benchBurst {
+		logInfo("Node %s: Sending a burst of %d messages took %s on average\n", n.Name, benchBurst, <-burstMean)
+	}
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	var total time.Duration
+	for _, l := range latencies {
+		total += l
+	}
+	mean := total / time.Duration(len(latencies))
+	p99 := latencies[(len(latencies)*99+99)/100-1]
+	logInfo("Node %s: %.0f msg/s, latency mean %s, p99 %s\n", n.Name, float64(benchN)/elapsed.Seconds(), mean, p99)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// benchmarkBurst runs the bursty pipeline of `bench -proto pipeline -burst`, over inproc and with queues of qlen messages (0 for the Mangos default). Each op sends a burst of messages back to back, and waits until the pull socket has received all of them.
+//
+// The consumer sets the pace, so ns/op hardly depends on the queues. What the queues change is how long the producer is stuck in Send(): the `burst-msg/s` metric is the rate at which the producer gets its bursts out. A burst that fits into the queues goes out at once; a larger one has to wait for the consumer.
+func benchmarkBurst(b *testing.B, qlen int) {
+	defer func(w, r int) { writeQLen, readQLen = w, r }(writeQLen, readQLen)
+	writeQLen, readQLen = qlen, qlen
+	const burst = 1000
+
+	n := newNode("bench")
+	pull, push := n.newPullSocket(testTimeout), n.newPushSocket()
+	defer pull.Close()
+	defer push.Close()
+	url := fmt.Sprintf("inproc://BenchmarkBurst-%d", qlen)
+	connected := onConnect(pull)
+	if err := listen(pull, url); err != nil {
+		b.Fatal(err)
+	}
+	if err := dial(push, url); err != nil {
+		b.Fatal(err)
+	}
+	select {
+	case <-connected:
+	case <-time.After(testTimeout):
+		b.Fatal("the push socket did not connect")
+	}
+
+	caughtUp := make(chan struct{})
+	go func() {
+		for i := 1; ; i++ {
+			if _, err := pull.Recv(); err != nil {
+				return
+			}
+			if i%burst == 0 {
+				caughtUp <- struct{}{}
+			}
+		}
+	}()
+	payload := makePayload(defaultBenchSize)
+	var sending time.Duration
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		start := time.Now()
+		for j := 0; j < burst; j++ {
+			if err := push.Send(payload); err != nil {
+				b.Fatal(err)
+			}
+		}
+		sending += time.Since(start)
+		select {
+		case <-caughtUp:
+		case <-time.After(testTimeout):
+			b.Fatal("the pull socket did not catch up")
+		}
+	}
+	b.ReportMetric(float64(b.N*burst)/sending.Seconds(), "burst-msg/s")
+}
+
+func BenchmarkBurstDefaultQueues(b *testing.B) { benchmarkBurst(b, 0) }
+
+func BenchmarkBurstLargeQueues(b *testing.B) { benchmarkBurst(b, 1024) }
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/go-mangos/mangos"
+	"github.com/go-mangos/mangos/protocol/bus"
+)
+
+// In the Bus example, every node is equal. Each node listens on its own URL and dials its peers, and every message a node sends reaches all nodes it is directly connected to.
+
+// newBusSocket creates a socket that speaks the BUS protocol.
+func (n *Node) newBusSocket(timeout time.Duration) mangos.Socket {
+	socket, err := bus.NewSocket()
+	if err != nil {
+		log.Fatalf("Node %s: Cannot create socket: %s\n", n.Name, err.Error())
+	}
+	n.setupSocket(socket, timeout)
+	return socket
+}
+
+// runBus listens on url and connects to the peers. It then sends five heartbeats, one per second, and prints all messages from the other nodes until none has arrived for the duration of the receive deadline.
+//
+// A bus socket sends each message once over every connection it has. If two nodes dialed each other, there would be two connections between them, and each message would arrive twice. To get exactly one connection per pair of nodes, a node only dials the peers whose URL sorts after its own URL; the other peers dial this node instead. This way, all nodes can get the same list of peers.
+//
+// A bus socket never delivers a node's own messages back to this node, so the receiving loop only sees messages from other nodes.
+func (n *Node) runBus(url string, peers []string, timeout time.Duration) {
+	// All bus nodes have the same name, "bus". To tell their messages apart, each node rather goes by its URL.
+	n.Name = url
+	n.sequences = newSequenceTracker()
+	if dedupWindow > 0 {
+		n.dedup = newDedupSet(dedupWindow)
+	}
+	socket := n.newBusSocket(timeout)
+	defer socket.Close()
+	err := listen(socket, url)
+	if err != nil {
+		log.Fatalf("Node %s cannot listen on socket '%s': %s\n", n.Name, url, err.Error())
+	}
+	for _, peer := range peers {
+		if peer <= url {
+			continue
+		}
+		err = dial(socket, peer)
+		if err != nil {
+			log.Fatalf("Node %s cannot dial on socket '%s': %s\n", n.Name, peer, err.Error())
+		}
+	}
+
+	n.socket = socket
+	defer n.startHeartbeat()()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 5; i++ {
+			// Sleeping first gives the connections some time to come up.
+			time.Sleep(1 * time.Second)
+			processing.Wait()
+			n.send(fmt.Sprintf("heartbeat %d from %s.", i, url))
+		}
+	}()
+	for {
+		processing.Wait()
+		_, err := n.Receive()
+		if errors.Is(err, ErrTimeout) || errors.Is(err, mangos.ErrClosed) {
+			break
+		}
+		if err != nil {
+			log.Fatalf("Node %s failed receiving a message: %s\n", n.Name, err.Error())
+		}
+	}
+	wg.Wait()
+	logInfo("Node %s: Done.\n", n.Name)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+)
+
+// interruptContext returns a context that gets cancelled when the process receives an interrupt signal (usually from Ctrl-C). A second interrupt kills the process as usual, in case the shutdown gets stuck.
+func interruptContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	go func() {
+		<-sig
+		signal.Stop(sig)
+		logInfo("Interrupted, shutting down.\n")
+		cancel()
+	}()
+	return ctx
+}
+
+// receiveResult is the outcome of a Receive() call that runs in the background.
+type receiveResult struct {
+	message Message
+	err     error
+}
+
+// receiveCtx works like Receive but returns ctx.Err() as soon as the context is cancelled.
+//
+// There is no way to abort a socket's Recv() call, so receiveCtx runs Receive in a goroutine of its own and simply stops waiting for it. The goroutine lingers until Recv() returns, which happens at the latest when the socket gets closed or the receive deadline passes. Its result stays pending in the node, where the next call to receiveCtx, or `drain()`, picks it up, so a message that it receives meanwhile is not lost.
+func (n *Node) receiveCtx(ctx context.Context) (Message, error) {
+	if n.pending == nil {
+		done := make(chan receiveResult, 1)
+		go func() {
+			message, err := n.Receive()
+			done <- receiveResult{message, err}
+		}()
+		n.pending = done
+	}
+	select {
+	case r := <-n.pending:
+		n.pending = nil
+		return r.message, r.err
+	case <-ctx.Done():
+		return Message{}, ctx.Err()
+	}
+}
+
+// sendCtx works like Send but returns ctx.Err() as soon as the context is cancelled. Like receiveCtx, it leaves the actual Send() call running in the background; the message may or may not get sent.
+func (n *Node) sendCtx(ctx context.Context, message string) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- n.Send(message)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
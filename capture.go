@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+)
+
+// The counterpart of `-replay` is `-capture`: a node appends every message that it receives to a file, one JSON object per line, with the sender, the sequence number, the body, the time it was sent, and the time it arrived. A capture file whose name ends in `.jsonl` can be replayed right away:
+//
+//	$ ./messaging -capture session.jsonl 1 tcp://localhost:54545
+//	$ ./messaging -replay session.jsonl 0 tcp://localhost:54545
+//
+// Writing each record straight to the file would cost a system call per message, so the records go through a buffer, which gets flushed every captureFlushInterval and when the node is done. Only the pair node shuts down cleanly when you press Ctrl-C; every other node would just die and take the buffer with it, so for them, the capture closes itself on an interrupt (see `closeOnInterrupt()`). A node that exits with a fatal error can still lose the records of the last interval.
+
+// captureFlushInterval is how often the capture buffer gets written to the file.
+const captureFlushInterval = time.Second
+
+// captured is the capture file of the node, or nil if there is none.
+var captured *capture
+
+// capture writes the received messages to a file.
+type capture struct {
+	mu   sync.Mutex
+	f    *os.File
+	w    *bufio.Writer
+	done chan struct{}
+	// flushed is closed when the flush goroutine has stopped.
+	flushed chan struct{}
+	// closeOnce lets both the end of main and an interrupt close the capture.
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// captureRecord is a line of the capture file.
+type captureRecord struct {
+	Message
+	Topic      string    `json:"topic,omitempty"`
+	ReceivedAt time.Time `json:"received_at"`
+}
+
+// openCapture opens the capture file for appending, creates it if necessary, and starts flushing it periodically. Opening the file at the start reveals an unwritable file before the node receives anything.
+func openCapture(path string) (*capture, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	c := &capture{
+		f:       f,
+		w:       bufio.NewWriter(f),
+		done:    make(chan struct{}),
+		flushed: make(chan struct{}),
+	}
+	go c.flushLoop()
+	return c, nil
+}
+
+// record appends m to the capture buffer. It does nothing if there is no capture file.
+func (c *capture) record(m Message, topic string) {
+	if c == nil {
+		return
+	}
+	line, err := json.Marshal(captureRecord{Message: m, Topic: topic, ReceivedAt: time.Now()})
+	if err != nil {
+		logError("Cannot capture message %d from %s: %s\n", m.Seq, m.From, err.Error())
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.w.Write(append(line, '\n'))
+}
+
+// flushLoop flushes the buffer every captureFlushInterval until Close is called.
+func (c *capture) flushLoop() {
+	defer close(c.flushed)
+	ticker := time.NewTicker(captureFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			if err := c.flush(); err != nil {
+				logError("Cannot write the capture file: %s\n", err.Error())
+				return
+			}
+		}
+	}
+}
+
+// flush writes the buffered records to the file. A bufio.Writer remembers its first error, so once writing has failed, flush keeps returning that error.
+func (c *capture) flush() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.w.Flush()
+}
+
+// Close stops the periodic flushing, writes the remaining records, and closes the file. Calling it again does nothing but return the result of the first call.
+func (c *capture) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		<-c.flushed
+		if err := c.flush(); err != nil {
+			c.f.Close()
+			c.closeErr = fmt.Errorf("cannot write the capture file: %w", err)
+			return
+		}
+		c.closeErr = c.f.Close()
+	})
+	return c.closeErr
+}
+
+// closeOnInterrupt closes the capture when the process receives an interrupt signal, and then exits with the status that a shell reports for Ctrl-C. Catching the signal turns off its default action, which is to end the process, so the handler has to end the process itself.
+func (c *capture) closeOnInterrupt() {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	go func() {
+		<-sig
+		if err := c.Close(); err != nil {
+			logError("%s\n", err.Error())
+		}
+		os.Exit(130)
+	}()
+}
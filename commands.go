@@ -0,0 +1,283 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// The program can run as many different kinds of node, and each kind has a few options that only it understands. Each kind of node is therefore a subcommand with a flag set of its own:
+//
+//	./messaging [options] <command> [command options] <args>
+//
+// The options in front of the command are the ones that all nodes share, like `-recv-timeout` or `-codec`. They may also follow the command. The options that only a command knows, like `-n` for `bench` or `-stdin` for `pair`, must follow the command; in front of it, they are an error rather than being silently ignored.
+//
+// The PAIR nodes of the article came first, and they need no command. If the first argument is not the name of a command, the program runs the `pair` command, so `./messaging 0 tcp://localhost:54545` still works, and so do the options of the `pair` command in front of the node name, as there is no other command that they could be confused with.
+
+// options holds the shared options that are not package variables of their own, as main() needs them only once.
+type options struct {
+	selftest    bool
+	validate    bool
+	dry         bool
+	jsonLogs    bool
+	verbose     bool
+	quiet       bool
+	waitURL     string
+	waitTimeout time.Duration
+	key         string
+	filter      string
+	perm        string
+	recvTimeout time.Duration
+	metricsAddr string
+	healthAddr  string
+	codecName   string
+	certFile    string
+	keyFile     string
+	caFile      string
+	allow       string
+	capture     string
+}
+
+// sharedFlags defines the options that all nodes share on fs.
+func sharedFlags(fs *flag.FlagSet, o *options) {
+	fs.IntVar(&maxPeers, "max-peers", 0, "maximum number of peers a listening node accepts at the same time (0 = no limit)")
+	fs.BoolVar(&o.validate, "validate", false, "check the URLs for problems without opening any socket, then exit")
+	fs.BoolVar(&o.dry, "dry-run", false, "check the options and the connection, then exit without sending any messages")
+	fs.BoolVar(&logConnections, "log-connections", false, "log when peers connect or disconnect")
+	fs.BoolVar(&o.jsonLogs, "json-logs", false, "log one JSON object per line instead of text, for log aggregators")
+	fs.BoolVar(&o.verbose, "v", false, "also log every message sent or received, with its full body")
+	fs.BoolVar(&o.quiet, "q", false, "log only failures and warnings")
+	fs.Float64Var(&logSample, "log-sample", 1, "fraction of sent and received messages to log, between 0 and 1 (errors are always logged)")
+	fs.StringVar(&o.waitURL, "wait-for", "", "URL of a dependency to wait for before starting (e.g. tcp://dep:5555)")
+	fs.DurationVar(&o.waitTimeout, "wait-timeout", 30*time.Second, "how long to wait for the -wait-for dependency")
+	fs.StringVar(&o.key, "encrypt-key", "", "hex-encoded 32-byte AES-256 key for encrypting message payloads with AES-GCM; both nodes need the same key")
+	fs.StringVar(&o.filter, "filter", "", "only process received messages that start with this prefix, or, if given as key=value, JSON messages whose field key equals value")
+	fs.IntVar(&maxMsgSize, "max-msg-size", maxMsgSize, "maximum size in bytes of a message that a node accepts (0 = no limit)")
+	fs.IntVar(&maxSendSize, "max-send-size", 0, "maximum payload size in bytes that a node sends (0 = no limit)")
+	fs.StringVar(&o.perm, "ipc-perm", "", "octal file mode for the socket file of an ipc listener, e.g. 0660")
+	// Invalid durations like `-recv-timeout=10` (without a unit) make `Parse()` fail with a usage message, rather than silently falling back to the default.
+	fs.DurationVar(&o.recvTimeout, "recv-timeout", 10*time.Second, "how long to wait for a message before giving up (0 = wait forever)")
+	fs.DurationVar(&sendTimeout, "send-timeout", 0, "how long to wait for room in the send buffer before giving up (0 = wait forever)")
+	fs.IntVar(&writeQLen, "write-qlen", 0, "number of messages that the write queue of a socket holds (0 = the default of 128)")
+	fs.IntVar(&readQLen, "read-qlen", 0, "number of messages that the read queue of a socket holds (0 = the default of 128)")
+	fs.DurationVar(&linger, "linger", linger, "how long closing a socket waits for pending messages to be sent (0 = drop them)")
+	fs.IntVar(&ttl, "ttl", 0, "number of hops a forwarded message may travel, from 1 to 255 (0 = the default of 8)")
+	fs.IntVar(&dialAttempts, "dial-attempts", dialAttempts, "how often a node tries to dial a URL before giving up on it")
+	fs.DurationVar(&maxDialBackoff, "max-dial-backoff", maxDialBackoff, "upper limit for the pause between two dial attempts, which doubles after each failed attempt")
+	fs.DurationVar(&reconnectTime, "reconnect", reconnectTime, "how soon a dialing node tries to reconnect after losing its connection")
+	fs.DurationVar(&maxReconnectTime, "max-reconnect", maxReconnectTime, "upper limit for the reconnect interval, which doubles after each failed attempt (0 = never grow)")
+	fs.BoolVar(&forceListen, "listen", false, "always listen on the URLs, never dial them")
+	fs.BoolVar(&forceDial, "dial", false, "always dial the URLs, never listen on them")
+	fs.BoolVar(&compressPayloads, "compress", false, "gzip the messages; both nodes must use the same setting")
+	fs.StringVar(&o.capture, "capture", "", "append every received message to this file as a JSON line (default: no capture)")
+	fs.StringVar(&o.metricsAddr, "metrics-addr", "", "address to serve Prometheus metrics on, e.g. :9100 (default: no metrics)")
+	fs.StringVar(&o.healthAddr, "health-addr", "", "address to serve health checks on at /healthz, e.g. :8080 (default: no health checks)")
+	fs.StringVar(&o.codecName, "codec", "json", "message encoding: json, gob, or protobuf; both nodes must use the same")
+	fs.DurationVar(&heartbeatInterval, "heartbeat", 0, "interval for sending keepalive messages on PAIR and BUS connections (0 = no keepalive)")
+	fs.StringVar(&o.certFile, "cert", "", "PEM file with the TLS certificate for tls+tcp URLs; required for listening")
+	fs.StringVar(&o.keyFile, "key", "", "PEM file with the private key for -cert")
+	fs.StringVar(&o.caFile, "cacert", "", "PEM file with the CA certificate that verifies the peer's TLS certificate")
+	fs.StringVar(&o.allow, "allow", "", "comma-separated list of IP addresses or CIDR ranges that may connect to a listening node (default: all)")
+}
+
+// pairFlags defines the options of the `pair` command.
+func pairFlags(fs *flag.FlagSet) {
+	fs.StringVar(&duplexMode, "mode-duplex", "duplex", "how the node interacts with its peer: duplex, pingpong, fire-forget, or receive-only")
+	fs.BoolVar(&readStdin, "stdin", false, "send the lines from standard input, and print received messages to standard output")
+	fs.BoolVar(&reliable, "reliable", false, "acknowledge each message, and resend messages that are not acknowledged in time")
+	fs.DurationVar(&ackTimeout, "ack-timeout", ackTimeout, "with -reliable, how long to wait for an acknowledgement before resending")
+	fs.IntVar(&ackRetries, "ack-retries", ackRetries, "with -reliable, how often to resend a message before giving up")
+	fs.IntVar(&sendQueueSize, "send-queue", 0, "send messages through a queue of this size, and drop messages while it is full (0 = no queue)")
+	fs.IntVar(&payloadSize, "size", 0, "size in bytes of generated messages to send (0 = text messages)")
+	fs.StringVar(&replayFile, "replay", "", "send the messages from this file, one per line, or JSON lines with a \"body\" field if the name ends in .jsonl")
+	fs.BoolVar(&replayLoop, "loop", false, "with -replay, start over at the end of the file until interrupted")
+	fs.BoolVar(&sendOnce, "once", false, "send one message, from -message or standard input, then exit")
+	fs.StringVar(&onceMessage, "message", "", "with -once, the message to send (default: read it from standard input)")
+	fs.BoolVar(&onceReply, "reply", false, "with -once, wait for one reply and print it to standard output")
+	fs.IntVar(&handlerWorkers, "workers", 0, "number of goroutines that handle the received messages (0 = handle them in the receive loop)")
+	fs.DurationVar(&drainTimeout, "drain-timeout", drainTimeout, "on an interrupt, how long to wait for more already-sent messages before stopping")
+	countFlags(fs)
+}
+
+// countFlags defines the options of the commands that send a number of messages at a certain pace.
+func countFlags(fs *flag.FlagSet) {
+	fs.IntVar(&messageCount, "count", messageCount, "number of messages to send (-1 = send until interrupted)")
+	fs.Float64Var(&sendRate, "rate", sendRate, "number of messages per second to send (0 = as fast as possible)")
+}
+
+// A command runs one kind of node.
+type command struct {
+	name string
+	// args describes the arguments that follow the command and its options.
+	args    string
+	help    string
+	minArgs int
+	// namedByArg is true if the first argument is the name of the node. Other nodes take the name of their command.
+	namedByArg bool
+	// handlesInterrupt is true if the node shuts down cleanly on an interrupt signal (see `interruptContext()`). All other nodes just die.
+	handlesInterrupt bool
+	// flags defines the options that only this command knows. It may be nil.
+	flags func(fs *flag.FlagSet)
+	// run runs the node, with the arguments that follow the node name.
+	run func(n *Node, args []string, timeout time.Duration)
+	fs  *flag.FlagSet
+}
+
+// commands are all commands, in the order of the usage message. The first one is the default.
+var commands = []*command{
+	{name: "pair", args: "0|1 <url> [url ...]", help: "exchange messages with one peer over PAIR (see the article)", minArgs: 2, namedByArg: true, handlesInterrupt: true, flags: pairFlags,
+		run: func(n *Node, args []string, timeout time.Duration) {
+			if readStdin {
+				n.OnMessage(printMessage)
+			}
+			n.Run(interruptContext(), args, timeout)
+		}},
+	{name: "pub", args: "<url>", help: "publish messages on a few topics", minArgs: 1,
+		run: func(n *Node, args []string, timeout time.Duration) { n.runPub(args[0]) }},
+	{name: "sub", args: "<url> [topic ...]", help: "subscribe to topics of a publisher", minArgs: 1,
+		run: func(n *Node, args []string, timeout time.Duration) { n.runSub(args[0], args[1:], timeout) }},
+	{name: "req", args: "<url>", help: "send requests and wait for the replies", minArgs: 1,
+		run: func(n *Node, args []string, timeout time.Duration) { n.runReq(args[0], timeout) }},
+	{name: "rep", args: "<url>", help: "reply to requests", minArgs: 1,
+		run: func(n *Node, args []string, timeout time.Duration) { n.runRep(args[0], timeout) }},
+	{name: "push", args: "<url>", help: "distribute work items to pull nodes", minArgs: 1,
+		run: func(n *Node, args []string, timeout time.Duration) { n.runPush(args[0]) }},
+	{name: "pull", args: "<url>", help: "take work items from a push node", minArgs: 1,
+		run: func(n *Node, args []string, timeout time.Duration) { n.runPull(args[0], timeout) }},
+	{name: "surveyor", args: "<url>", help: "ask all respondents and collect their answers", minArgs: 1,
+		flags: func(fs *flag.FlagSet) {
+			fs.DurationVar(&surveyTime, "survey-time", time.Second, "how long to wait for responses")
+		},
+		run: func(n *Node, args []string, timeout time.Duration) { n.runSurveyor(args[0]) }},
+	{name: "respondent", args: "<url> [id]", help: "answer the surveys of a surveyor", minArgs: 1,
+		run: func(n *Node, args []string, timeout time.Duration) {
+			id := ""
+			if len(args) > 1 {
+				id = args[1]
+			}
+			n.runRespondent(args[0], id, timeout)
+		}},
+	{name: "bus", args: "<url> [peer-url ...]", help: "exchange messages with all peers of a bus", minArgs: 1,
+		flags: func(fs *flag.FlagSet) {
+			fs.IntVar(&dedupWindow, "dedup-window", dedupWindow, "number of recent message ids to remember for dropping duplicates (0 = keep duplicates)")
+		},
+		run: func(n *Node, args []string, timeout time.Duration) { n.runBus(args[0], args[1:], timeout) }},
+	{name: "pool", args: "<url> [url ...]", help: "send to several PAIR nodes in turn", minArgs: 1, flags: countFlags,
+		run: func(n *Node, args []string, timeout time.Duration) { n.runPool(args) }},
+	{name: "wsmux", args: "<url> <url> [url ...]", help: "listen on several paths of one WebSocket port, with one socket per path", minArgs: 2,
+		run: func(n *Node, args []string, timeout time.Duration) { n.runWSMux(args, timeout) }},
+	{name: "bench", help: "measure the throughput and latency of a protocol and transport",
+		flags: func(fs *flag.FlagSet) {
+			fs.IntVar(&benchN, "n", benchN, "number of messages to send")
+			fs.IntVar(&payloadSize, "size", 0, "size in bytes of the messages (0 = 100 bytes)")
+			fs.StringVar(&benchURL, "url", benchURL, "URL to run the benchmark over")
+			fs.StringVar(&benchProto, "proto", benchProto, "protocol to benchmark: pair, reqrep, or pipeline")
+			fs.IntVar(&benchBurst, "burst", 0, "with -proto pipeline, send the messages in bursts of this size, and report how long sending a burst takes (0 = no bursts)")
+		},
+		run: func(n *Node, args []string, timeout time.Duration) { n.runBench(timeout) }},
+}
+
+// lookupCommand returns the command with the given name, or nil.
+func lookupCommand(name string) *command {
+	for _, c := range commands {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// defineFlags creates the flag sets of all commands, and adds the shared options to the top-level flag set.
+//
+// All flag sets must exist before the first one parses the command line, as defining an option sets its variable to the default value, which would overwrite what an earlier flag set has parsed.
+func defineFlags(o *options) {
+	flag.BoolVar(&o.selftest, "selftest", false, "run two nodes in this process, let them exchange a few messages, and report whether it worked")
+	sharedFlags(flag.CommandLine, o)
+	flag.Usage = usage
+	for _, c := range commands {
+		c.fs = flag.NewFlagSet(c.name, flag.ExitOnError)
+		sharedFlags(c.fs, o)
+		if c.flags != nil {
+			c.flags(c.fs)
+		}
+		c := c
+		c.fs.Usage = func() {
+			fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s %s [options] %s\n\nThe %s command: %s.\n\nOptions:\n", os.Args[0], c.name, c.args, c.name, c.help)
+			c.fs.PrintDefaults()
+		}
+	}
+}
+
+// parseCommand parses the command line and returns the command to run, and the arguments that follow the command and its options. Whatever the command line leaves out may come from the environment (see `env.go`). If there is no command at all, parseCommand returns nil.
+//
+// Which flag set parses the options in front of the first argument depends on that argument: if it names a command, the top-level flag set takes them, and it only knows the shared options. Otherwise, they belong to the default command, and its flag set takes the whole command line.
+func parseCommand() (*command, []string) {
+	args := os.Args[1:]
+	i := firstArg(args, commands[0].fs)
+	var c *command
+	switch {
+	case i >= 0 && lookupCommand(args[i]) != nil:
+		flag.CommandLine.Parse(args[:i])
+		c = lookupCommand(args[i])
+		c.fs.Parse(args[i+1:])
+	case i >= 0:
+		c = commands[0]
+		c.fs.Parse(args)
+	case os.Getenv("MSG_PROTO") != "" || os.Getenv("MSG_NODE") != "":
+		c = envCommand()
+		if c == nil {
+			log.Fatalf("Unknown command '%s' in MSG_PROTO\n", os.Getenv("MSG_PROTO"))
+		}
+		c.fs.Parse(args)
+	default:
+		flag.Parse()
+		return nil, nil
+	}
+	if err := applyEnvFlags(c.fs, flag.CommandLine); err != nil {
+		log.Fatalf("Invalid environment: %s\n", err.Error())
+	}
+	return c, envArgs(c, c.fs.Args())
+}
+
+// firstArg returns the index of the first argument that is neither an option nor the value of an option, or -1 if there is none. It steps over the options the way that fs would parse them, but without setting them. An option that fs does not know counts as one without a value; parsing reports it later anyway.
+func firstArg(args []string, fs *flag.FlagSet) int {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			if i+1 < len(args) {
+				return i + 1
+			}
+			return -1
+		}
+		if len(arg) < 2 || arg[0] != '-' {
+			return i
+		}
+		name := strings.TrimLeft(arg, "-")
+		if strings.Contains(name, "=") {
+			continue
+		}
+		f := fs.Lookup(name)
+		if f == nil {
+			continue
+		}
+		if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+			continue
+		}
+		i++
+	}
+	return -1
+}
+
+// usage lists the commands and the shared options.
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: %s [options] <command> [command options] <args>\n       %s [options] 0|1 <url> [url ...]\n\nCommands:\n", os.Args[0], os.Args[0])
+	for _, c := range commands {
+		fmt.Fprintf(out, "  %-10s %-22s %s\n", c.name, c.args, c.help)
+	}
+	fmt.Fprintf(out, "\nRun '%s <command> -h' for the options of a command.\n\nOptions:\n", os.Args[0])
+	flag.PrintDefaults()
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"flag"
+	"testing"
+)
+
+func TestFirstArg(t *testing.T) {
+	var o options
+	fs := flag.NewFlagSet("pair", flag.ContinueOnError)
+	sharedFlags(fs, &o)
+	pairFlags(fs)
+	tests := []struct {
+		args []string
+		want int
+	}{
+		{[]string{"pub", "tcp://localhost:54545"}, 0},
+		{[]string{"-v", "pub", "tcp://localhost:54545"}, 1},
+		{[]string{"-recv-timeout", "5s", "sub", "tcp://localhost:54545"}, 2},
+		{[]string{"-recv-timeout=5s", "sub"}, 1},
+		{[]string{"--codec", "gob", "-stdin", "0", "tcp://localhost:54545"}, 3},
+		{[]string{"-dry-run", "--", "-1"}, 2},
+		{[]string{"-v", "-selftest"}, -1},
+		{[]string{}, -1},
+	}
+	for _, tt := range tests {
+		if got := firstArg(tt.args, fs); got != tt.want {
+			t.Errorf("firstArg(%q) = %d, want %d", tt.args, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// compressPayloads turns on gzip compression of the encoded messages. Large text payloads shrink considerably, which pays off on slow TCP links. Both peers must use the same setting: a node that does not expect compressed messages fails to decode them, and a node that expects them fails to decompress uncompressed messages.
+var compressPayloads bool
+
+// compress gzips data.
+func compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decompress reverses compress. A few kilobytes of gzip data can unpack to gigabytes, so decompress applies the maximum message size to the decompressed data, too.
+func decompress(data []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	if maxMsgSize <= 0 {
+		return ioutil.ReadAll(zr)
+	}
+	out, err := ioutil.ReadAll(io.LimitReader(zr, int64(maxMsgSize)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(out) > maxMsgSize {
+		return nil, fmt.Errorf("decompressed message exceeds %d bytes", maxMsgSize)
+	}
+	return out, nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCompressRoundTrip(t *testing.T) {
+	data := []byte(strings.Repeat("The quick brown fox jumps over the lazy dog. ", 100))
+	packed, err := compress(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(packed) >= len(data) {
+		t.Errorf("compressed %d bytes to %d bytes", len(data), len(packed))
+	}
+	unpacked, err := decompress(packed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(unpacked, data) {
+		t.Error("decompressed data differs from the original")
+	}
+}
+
+// A peer without -compress sends plain data, which decompress must reject rather than misread.
+func TestDecompressRejectsPlainData(t *testing.T) {
+	if _, err := decompress([]byte(`{"from":"node1"}`)); err == nil {
+		t.Error("decompress accepted uncompressed data")
+	}
+}
+
+func TestDecompressHonoursMaxMsgSize(t *testing.T) {
+	defer func(size int) { maxMsgSize = size }(maxMsgSize)
+	maxMsgSize = 1024
+	packed, err := compress(make([]byte, 10*maxMsgSize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := decompress(packed); err == nil {
+		t.Error("decompress unpacked more than -max-msg-size bytes")
+	}
+}
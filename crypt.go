@@ -0,0 +1,59 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// payloadCipher encrypts and decrypts message payloads. It is nil unless the user provides a key, in which case messages are sent unencrypted.
+var payloadCipher cipher.AEAD
+
+// keySize is the size of an AES-256 key in bytes.
+const keySize = 32
+
+// newPayloadCipher creates an AES-256-GCM cipher from a hex-encoded key of 32 bytes, that is, 64 hex digits. A command like `openssl rand -hex 32` creates a suitable key.
+//
+// GCM is an AEAD ("authenticated encryption with associated data") mode: besides keeping the content secret, it detects any change to the ciphertext, so a receiver never gets to see a tampered message.
+func newPayloadCipher(hexKey string) (cipher.AEAD, error) {
+	key, err := hex.DecodeString(hexKey)
+	if err != nil {
+		return nil, fmt.Errorf("key is not hex-encoded: %s", err.Error())
+	}
+	if len(key) != keySize {
+		return nil, fmt.Errorf("key has %d bytes, but AES-256 needs %d bytes (%d hex digits)", len(key), keySize, 2*keySize)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encrypt seals the payload with a fresh random nonce. The nonce goes in front of the ciphertext, where it acts as a small header that the receiver needs for decrypting.
+//
+// Encryption happens before the payload reaches the socket, so it protects the content end-to-end, no matter which transport carries it.
+func encrypt(payload []byte) ([]byte, error) {
+	nonce := make([]byte, payloadCipher.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return payloadCipher.Seal(nonce, nonce, payload, nil), nil
+}
+
+// decrypt splits off the nonce and opens the ciphertext. It fails if the message was not encrypted with the same key or was tampered with.
+func decrypt(data []byte) ([]byte, error) {
+	n := payloadCipher.NonceSize()
+	if len(data) < n {
+		return nil, errors.New("encrypted message too short")
+	}
+	payload, err := payloadCipher.Open(nil, data[:n], data[n:], nil)
+	if err != nil {
+		// GCM does not tell a wrong key from a tampered message, and neither can we.
+		return nil, errors.New("decryption failed: wrong key, or the message was tampered with")
+	}
+	return payload, nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"container/list"
+	"fmt"
+)
+
+// In our BUS example, each pair of nodes has exactly one connection (see `runBus()`). Bigger BUS networks are often less tidy: if node A is connected to B and C, and both B and C forward what they get to D, then D receives each message of A twice, once through B and once through C. To drop such duplicates, a node remembers the ids of the messages it has seen recently. The id of a message is its origin together with its sequence number, which no other message shares.
+//
+// The node cannot remember all messages forever, so it keeps only the last dedupWindow ids, and forgets the one it has seen least recently when a new id arrives. A duplicate that arrives after more than dedupWindow other messages gets through.
+var dedupWindow = 1024
+
+// messageID identifies a message across all nodes.
+func messageID(m Message) string {
+	return fmt.Sprintf("%s#%d", m.From, m.Seq)
+}
+
+// dedupSet is a set of message ids with a limited size. When the set is full, adding an id evicts the least recently seen one.
+type dedupSet struct {
+	size  int
+	order *list.List // the ids, most recently seen first
+	ids   map[string]*list.Element
+}
+
+// newDedupSet creates an empty set that holds up to size ids.
+func newDedupSet(size int) *dedupSet {
+	return &dedupSet{size: size, order: list.New(), ids: map[string]*list.Element{}}
+}
+
+// seen adds id to the set and reports whether it was already there.
+func (d *dedupSet) seen(id string) bool {
+	if e, ok := d.ids[id]; ok {
+		d.order.MoveToFront(e)
+		return true
+	}
+	d.ids[id] = d.order.PushFront(id)
+	if d.order.Len() > d.size {
+		oldest := d.order.Back()
+		d.order.Remove(oldest)
+		delete(d.ids, oldest.Value.(string))
+	}
+	return false
+}
+
+// isDuplicate reports whether the node has seen m before. Nodes without a dedup set see no duplicates.
+func (n *Node) isDuplicate(m Message) bool {
+	if n.dedup == nil || !n.dedup.seen(messageID(m)) {
+		return false
+	}
+	logInfo("Node %s: Dropped duplicate of message %d from %s\n", n.Name, m.Seq, m.From)
+	return true
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-mangos/mangos"
+)
+
+func TestDedupSetEvictsLeastRecentlySeen(t *testing.T) {
+	d := newDedupSet(2)
+	for _, id := range []string{"a#1", "b#1"} {
+		if d.seen(id) {
+			t.Fatalf("%s seen before it was added", id)
+		}
+	}
+	if !d.seen("a#1") {
+		t.Fatal("a#1 not seen")
+	}
+	// b#1 is now the least recently seen id, so c#1 pushes it out.
+	d.seen("c#1")
+	if !d.seen("a#1") {
+		t.Error("a#1 evicted instead of b#1")
+	}
+	if d.seen("b#1") {
+		t.Error("b#1 still in the set")
+	}
+}
+
+// A diamond: node A is connected to B and C, and both of them forward everything they get to D. D receives each message of A twice, but must deliver it only once.
+func TestBusDiamondDropsDuplicates(t *testing.T) {
+	nodes := map[string]*Node{}
+	for _, name := range []string{"A", "B", "C", "D"} {
+		n := newNode(name)
+		n.socket = n.newBusSocket(testTimeout)
+		defer n.socket.Close()
+		nodes[name] = n
+	}
+	a, d := nodes["A"], nodes["D"]
+	d.dedup = newDedupSet(dedupWindow)
+	urlA, urlD := testURL(t, "-A"), testURL(t, "-D")
+	connectedA, connectedD := onConnect(a.socket), onConnect(d.socket)
+	if err := listen(a.socket, urlA); err != nil {
+		t.Fatal(err)
+	}
+	if err := listen(d.socket, urlD); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"B", "C"} {
+		relay := nodes[name].socket
+		for _, link := range []struct {
+			url       string
+			connected <-chan struct{}
+		}{{urlA, connectedA}, {urlD, connectedD}} {
+			if err := dial(relay, link.url); err != nil {
+				t.Fatal(err)
+			}
+			select {
+			case <-link.connected:
+			case <-time.After(testTimeout):
+				t.Fatalf("%s did not connect to %s", name, link.url)
+			}
+		}
+		go func() {
+			for {
+				payload, err := relay.Recv()
+				if errors.Is(err, mangos.ErrClosed) {
+					return
+				}
+				if err == nil {
+					relay.Send(payload)
+				}
+			}
+		}()
+	}
+
+	if err := a.Send("hello"); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Send("bye"); err != nil {
+		t.Fatal(err)
+	}
+	expectBody(t, d, "hello")
+	expectBody(t, d, "bye")
+	d.socket.SetOption(mangos.OptionRecvDeadline, 200*time.Millisecond)
+	if m, err := d.Receive(); err == nil {
+		t.Errorf("D received '%s' from %s a second time", m.Body, m.From)
+	}
+}
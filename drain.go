@@ -0,0 +1,40 @@
+package main
+
+import (
+	"time"
+
+	"github.com/go-mangos/mangos"
+)
+
+// When the user interrupts a node, some messages may already sit in the socket's receive buffer, and one may be on its way out of a pending `Recv()` (see `receiveCtx()`). Simply closing the socket would silently lose them. So before it stops, the receive loop drains the socket: it takes whatever is still there, until no message has arrived for drainTimeout. The timeout is kept short, as the node should not keep running on a steady stream of new messages.
+var drainTimeout = 500 * time.Millisecond
+
+// drain hands the messages that are still buffered to handle, and returns the number of drained messages. It first collects the result of a Receive() that receiveCtx has left pending, then receives with a receive deadline of timeout until the deadline passes or the socket gets closed.
+//
+// The pending Receive() still runs with the receive deadline that was set when it started, which may be long, or none at all with `-recv-timeout 0`. So drain waits no longer than timeout for it, too. If the pending Receive() has not returned by then, the socket has no message waiting, and there is nothing left to drain.
+func (n *Node) drain(timeout time.Duration, handle func(Message)) int {
+	drained := 0
+	if n.pending != nil {
+		select {
+		case r := <-n.pending:
+			n.pending = nil
+			if r.err == nil {
+				handle(r.message)
+				drained++
+			}
+		case <-time.After(timeout):
+			return drained
+		}
+	}
+	if err := n.socket.SetOption(mangos.OptionRecvDeadline, timeout); err != nil {
+		return drained
+	}
+	for {
+		m, err := n.Receive()
+		if err != nil {
+			return drained
+		}
+		handle(m)
+		drained++
+	}
+}
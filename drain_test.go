@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-mangos/mangos"
+)
+
+// While the node is paused, messages pile up in its socket. On shutdown, the receive loop must hand every one of them to the handler before it returns.
+func TestShutdownDrainsBufferedMessages(t *testing.T) {
+	l, d := newTestPair(t)
+	defer closeNodes(l, d)
+	var got []string
+	l.OnMessage(func(m Message) { got = append(got, m.Body) })
+
+	processing.Pause()
+	defer processing.Resume()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		l.receiveLoop(ctx)
+	}()
+	for i := 0; i < 5; i++ {
+		if err := d.Send(fmt.Sprintf("message %d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(testTimeout):
+		t.Fatal("the receive loop did not stop")
+	}
+	if len(got) != 5 {
+		t.Fatalf("handled %d messages, want 5: %v", len(got), got)
+	}
+	for i, body := range got {
+		if want := fmt.Sprintf("message %d", i); body != want {
+			t.Errorf("message %d: got '%s', want '%s'", i, body, want)
+		}
+	}
+}
+
+// Without a receive deadline, a pending Receive() waits forever on a quiet socket. drain must not wait for it any longer than its own timeout.
+func TestDrainDoesNotWaitForPendingReceive(t *testing.T) {
+	l, d := newTestPair(t)
+	defer closeNodes(l, d)
+	if err := l.socket.SetOption(mangos.OptionRecvDeadline, time.Duration(0)); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	l.receiveCtx(ctx)
+	if l.pending == nil {
+		t.Fatal("receiveCtx left no Receive() pending")
+	}
+	start := time.Now()
+	if drained := l.drain(100*time.Millisecond, func(Message) {}); drained != 0 {
+		t.Errorf("drained %d messages from a quiet socket", drained)
+	}
+	if took := time.Since(start); took > time.Second {
+		t.Errorf("drain took %s", took)
+	}
+}
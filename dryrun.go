@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// dryRunTimeout is how long a dry run waits for a dialed connection to come up.
+const dryRunTimeout = 10 * time.Second
+
+// dryRun verifies the node's setup without sending any messages. It creates the socket, listens on the URL or dials it like `Run()` does (including the -listen and -dial overrides), and closes the socket again right away.
+//
+// Mangos dials in the background, so a successful Dial() only means that the URL is valid. To verify that the connection actually gets established, dryRun waits until the socket reports a new connection.
+func (n *Node) dryRun(url string, timeout time.Duration) error {
+	socket, err := n.newSocket(timeout)
+	if err != nil {
+		return err
+	}
+	defer socket.Close()
+
+	connected := onConnect(socket)
+
+	if !forceDial {
+		err := listen(socket, url)
+		if err == nil {
+			logInfo("Node %s dry run: listening on socket '%s' works\n", n.Name, url)
+			return nil
+		}
+		if forceListen {
+			return fmt.Errorf("cannot listen on socket '%s': %s", url, err.Error())
+		}
+		logInfo("Node %s cannot listen on socket '%s': %s\nTrying to dial instead\n", n.Name, url, err.Error())
+	}
+	err = dial(socket, url)
+	if err != nil {
+		return fmt.Errorf("can neither listen nor dial on socket '%s': %s", url, err.Error())
+	}
+	select {
+	case <-connected:
+		logInfo("Node %s dry run: connected to socket '%s'\n", n.Name, url)
+		return nil
+	case <-time.After(dryRunTimeout):
+		return fmt.Errorf("no connection to socket '%s' within %s", url, dryRunTimeout)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// duplexMode selects how a node interacts with its peer:
+//
+// * "duplex" sends and receives at the same time, in two goroutines.
+// * "pingpong" sends a message and waits for the reply before sending the next one.
+// * "fire-forget" sends messages without waiting, and receives whatever comes in the background.
+// * "receive-only" never sends and only consumes messages.
+var duplexMode = "duplex"
+
+// pingPong is a simple loop that sends a message and then waits for a reply. It then sleeps for one second, for a more dramatic effect in your terminal, and repeats.
+//
+// Before each round, the node checks if an operator has paused it (see `pause.go`).
+//
+// If no reply arrives in time, the node just moves on to the next round. Any other receive error means that something is seriously wrong, so the node stops. So does an interrupt.
+func (n *Node) pingPong(ctx context.Context) {
+	for i := 0; messageCount < 0 || i < messageCount; i++ {
+		if processing.WaitCtx(ctx) != nil {
+			return
+		}
+		n.send(fmt.Sprintf("message %d from node %s.", i, n.Name))
+		_, err := n.Receive()
+		if errors.Is(err, ErrTimeout) {
+			logError("Node %s received no reply to message %d: %s\n", n.Name, i, err.Error())
+			continue
+		}
+		if err != nil {
+			logError("Node %s failed receiving a message: %s\n", n.Name, err.Error())
+			break
+		}
+		time.Sleep(1 * time.Second)
+	}
+}
+
+// fireAndForget sends messages without waiting for replies. Unlike duplex, it does not wait for the peer to finish: whatever the peer sends while fireAndForget is sending gets logged by a background receiver, and everything else is ignored.
+func (n *Node) fireAndForget(ctx context.Context) {
+	go n.receiveLoop(ctx)
+	n.sendLoop(ctx)
+}
+
+// receiveOnly consumes messages until the receive deadline passes without a new message.
+func (n *Node) receiveOnly(ctx context.Context) {
+	n.receiveLoop(ctx)
+}
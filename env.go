@@ -0,0 +1,66 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// In a container, it is often easier to pass settings through environment variables than through the command line. So for the settings that usually differ between deployments, a node falls back to environment variables:
+//
+// * MSG_PROTO is the command, like `pub` or `pair`, if the command line has none.
+// * MSG_NODE is the name of a PAIR node, if the command line has none.
+// * MSG_URL is the URL, or a comma-separated list of URLs, if the command line has none. For `bench`, it sets `-url`.
+// * MSG_RECV_TIMEOUT and MSG_SEND_TIMEOUT set `-recv-timeout` and `-send-timeout`.
+//
+// The command line always wins: an environment variable only applies if the command line leaves the setting out. And if neither sets it, the default applies, as usual.
+
+// envFlags maps the options that can be set through the environment to their variables.
+var envFlags = map[string]string{
+	"recv-timeout": "MSG_RECV_TIMEOUT",
+	"send-timeout": "MSG_SEND_TIMEOUT",
+	"url":          "MSG_URL",
+}
+
+// envCommand returns the command from MSG_PROTO, or the default command if MSG_PROTO is not set. It returns nil if there is no such command.
+func envCommand() *command {
+	name := os.Getenv("MSG_PROTO")
+	if name == "" {
+		return commands[0]
+	}
+	return lookupCommand(name)
+}
+
+// envArgs adds the node name from MSG_NODE and the URLs from MSG_URL to the arguments of c, unless the arguments already have them.
+func envArgs(c *command, args []string) []string {
+	urlIndex := 0
+	if c.namedByArg {
+		urlIndex = 1
+		if node := os.Getenv("MSG_NODE"); len(args) == 0 && node != "" {
+			args = []string{node}
+		}
+	}
+	if urls := os.Getenv("MSG_URL"); len(args) == urlIndex && urls != "" && c.args != "" {
+		args = append(args, strings.Split(urls, ",")...)
+	}
+	return args
+}
+
+// applyEnvFlags sets the options of fs that the command line has left out from the environment. top is the flag set that has parsed the options in front of the command; options that it has seen count as set, too.
+func applyEnvFlags(fs, top *flag.FlagSet) error {
+	set := map[string]bool{}
+	mark := func(f *flag.Flag) { set[f.Name] = true }
+	top.Visit(mark)
+	fs.Visit(mark)
+	for name, env := range envFlags {
+		value, ok := os.LookupEnv(env)
+		if !ok || set[name] || fs.Lookup(name) == nil {
+			continue
+		}
+		if err := fs.Set(name, value); err != nil {
+			return fmt.Errorf("%s='%s': %w", env, value, err)
+		}
+	}
+	return nil
+}
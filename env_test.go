@@ -0,0 +1,121 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newEnvTestFlagSets returns a top-level and a command flag set that share the -recv-timeout option, like the ones that defineFlags creates.
+func newEnvTestFlagSets(timeout *time.Duration) (topFS, cmdFS *flag.FlagSet) {
+	topFS = flag.NewFlagSet("top", flag.ContinueOnError)
+	topFS.DurationVar(timeout, "recv-timeout", 10*time.Second, "")
+	cmdFS = flag.NewFlagSet("cmd", flag.ContinueOnError)
+	cmdFS.DurationVar(timeout, "recv-timeout", 10*time.Second, "")
+	return topFS, cmdFS
+}
+
+// setenv sets the environment variable key to value, and returns a function that restores the variable's previous state, for the test to defer.
+func setenv(t *testing.T, key, value string) (restore func()) {
+	t.Helper()
+	old, ok := os.LookupEnv(key)
+	if err := os.Setenv(key, value); err != nil {
+		t.Fatal(err)
+	}
+	return func() {
+		if ok {
+			os.Setenv(key, old)
+		} else {
+			os.Unsetenv(key)
+		}
+	}
+}
+
+func TestEnvFlagPrecedence(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     string
+		topArgs []string
+		cmdArgs []string
+		want    time.Duration
+	}{
+		{"default", "", nil, nil, 10 * time.Second},
+		{"env over default", "3s", nil, nil, 3 * time.Second},
+		{"flag over env", "3s", nil, []string{"-recv-timeout", "5s"}, 5 * time.Second},
+		{"flag in front of the command over env", "3s", []string{"-recv-timeout", "7s"}, nil, 7 * time.Second},
+		{"flag without env", "", nil, []string{"-recv-timeout", "5s"}, 5 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.env != "" {
+				defer setenv(t, "MSG_RECV_TIMEOUT", tt.env)()
+			}
+			var timeout time.Duration
+			topFS, cmdFS := newEnvTestFlagSets(&timeout)
+			if err := topFS.Parse(tt.topArgs); err != nil {
+				t.Fatal(err)
+			}
+			if err := cmdFS.Parse(tt.cmdArgs); err != nil {
+				t.Fatal(err)
+			}
+			if err := applyEnvFlags(cmdFS, topFS); err != nil {
+				t.Fatal(err)
+			}
+			if timeout != tt.want {
+				t.Errorf("got %s, want %s", timeout, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnvFlagInvalid(t *testing.T) {
+	defer setenv(t, "MSG_RECV_TIMEOUT", "10")()
+	var timeout time.Duration
+	topFS, cmdFS := newEnvTestFlagSets(&timeout)
+	if err := applyEnvFlags(cmdFS, topFS); err == nil {
+		t.Error("accepted a timeout without a unit")
+	}
+}
+
+func TestEnvArgs(t *testing.T) {
+	pair, pub := lookupCommand("pair"), lookupCommand("pub")
+	tests := []struct {
+		name      string
+		node, url string
+		c         *command
+		args      []string
+		want      []string
+	}{
+		{"command line only", "", "", pair, []string{"0", "tcp://a:1"}, []string{"0", "tcp://a:1"}},
+		{"node and URLs from env", "1", "tcp://a:1,tcp://b:2", pair, nil, []string{"1", "tcp://a:1", "tcp://b:2"}},
+		{"URL from env", "", "tcp://a:1", pair, []string{"0"}, []string{"0", "tcp://a:1"}},
+		{"command line over env", "1", "tcp://b:2", pair, []string{"0", "tcp://a:1"}, []string{"0", "tcp://a:1"}},
+		{"no node name for pub", "1", "tcp://a:1", pub, nil, []string{"tcp://a:1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer setenv(t, "MSG_NODE", tt.node)()
+			defer setenv(t, "MSG_URL", tt.url)()
+			if got := envArgs(tt.c, tt.args); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnvCommand(t *testing.T) {
+	defer setenv(t, "MSG_PROTO", "")()
+	if c := envCommand(); c != commands[0] {
+		t.Errorf("without MSG_PROTO, got %v, want the default command", c.name)
+	}
+	os.Setenv("MSG_PROTO", "sub")
+	if c := envCommand(); c == nil || c.name != "sub" {
+		t.Errorf("MSG_PROTO=sub: got %v", c)
+	}
+	os.Setenv("MSG_PROTO", "nonsense")
+	if c := envCommand(); c != nil {
+		t.Errorf("MSG_PROTO=nonsense: got %s, want nil", c.name)
+	}
+}
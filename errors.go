@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/go-mangos/mangos"
+)
+
+// Errors come in many shapes: Mangos has its own error values, the codecs and middlewares return theirs, and some errors are our own. A caller that wants to react to an error, say, to retry after a timeout but to give up on a message that cannot be decoded, should not need to know all of them. So the node sorts its errors into a few classes, which callers can test with `errors.Is()`:
+//
+//	if errors.Is(err, ErrTimeout) { ... }
+//
+// The underlying error stays available, too: `errors.Is(err, mangos.ErrRecvTimeout)` still works, and `errors.As()` gets the `*Error` with both.
+var (
+	// ErrNoConnection tells that there is no peer to talk to: dialing failed for good, or no peer connected in time.
+	ErrNoConnection = errors.New("no connection")
+	// ErrTimeout tells that a deadline has passed: for sending, for receiving, or for an ACK.
+	ErrTimeout = errors.New("timeout")
+	// ErrEncode tells that a message could not be turned into a payload.
+	ErrEncode = errors.New("cannot encode message")
+	// ErrDecode tells that a payload could not be turned back into a message, for example because the peer uses a different codec or key.
+	ErrDecode = errors.New("cannot decode message")
+)
+
+// Error is an error of one of the classes above, together with the error that caused it.
+type Error struct {
+	// Class is ErrNoConnection, ErrTimeout, ErrEncode, or ErrDecode.
+	Class error
+	Err   error
+}
+
+func (e *Error) Error() string {
+	return e.Class.Error() + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is the class of e.
+func (e *Error) Is(target error) bool {
+	return target == e.Class
+}
+
+// classify puts the Mangos timeout errors into the class ErrTimeout. Any other error, including nil, comes back as it is.
+func classify(err error) error {
+	if err == mangos.ErrRecvTimeout || err == mangos.ErrSendTimeout {
+		return &Error{Class: ErrTimeout, Err: err}
+	}
+	return err
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// messageFilter decides, based on its body, whether a received message gets processed or dropped. If it is nil, all messages pass.
+var messageFilter func([]byte) bool
+
+// dropped counts the messages that messageFilter has rejected.
+var dropped uint64
+
+// parseFilter turns a filter expression into a predicate. There are two kinds of expressions:
+//
+// * `key=value` accepts message bodies that are JSON objects whose top-level field `key` equals `value`. Bodies that are not JSON objects are rejected.
+// * Anything else is a prefix that the message body must start with.
+func parseFilter(expr string) func([]byte) bool {
+	eq := strings.Index(expr, "=")
+	if eq < 0 {
+		prefix := []byte(expr)
+		return func(msg []byte) bool {
+			return bytes.HasPrefix(msg, prefix)
+		}
+	}
+	key, value := expr[:eq], expr[eq+1:]
+	return func(msg []byte) bool {
+		var fields map[string]interface{}
+		if json.Unmarshal(msg, &fields) != nil {
+			return false
+		}
+		v, ok := fields[key]
+		return ok && fmt.Sprint(v) == value
+	}
+}
+
+// accept applies the message filter to a message body and counts the messages it drops.
+func accept(body []byte) bool {
+	if messageFilter == nil || messageFilter(body) {
+		return true
+	}
+	atomic.AddUint64(&dropped, 1)
+	return false
+}
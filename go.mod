@@ -5,4 +5,5 @@ go 1.13
 require (
 	github.com/go-mangos/mangos v1.1.1-0.20160525152327-83e303c317b5
 	github.com/gorilla/websocket v1.4.2 // indirect
+	google.golang.org/protobuf v1.26.0
 )
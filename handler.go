@@ -0,0 +1,49 @@
+package main
+
+import (
+	"sync"
+)
+
+// To use a node as a building block, application code needs to get hold of the messages that the node receives. It can register a handler with `OnMessage()`, and the receive loop calls the handler for each message (see `receiveLoop()`).
+//
+// A slow handler would hold up the receive loop, and with it, the socket's read queue. With `-workers`, the receive loop rather hands the messages to a pool of goroutines that run the handler. The messages then no longer get handled in the order of arrival, and the handler must be safe for concurrent use.
+var handlerWorkers int
+
+// OnMessage registers the function that handles the received messages. Register the handler before the node runs.
+func (n *Node) OnMessage(handler func(Message)) {
+	n.handler = handler
+}
+
+// startHandlers starts handlerWorkers goroutines that call the handler. Calling the returned function lets them handle the messages that are still waiting, and then stops them. Without a handler or without workers, there is nothing to start.
+func (n *Node) startHandlers() (stop func()) {
+	if n.handler == nil || handlerWorkers <= 0 {
+		return func() {}
+	}
+	n.work = make(chan Message, handlerWorkers)
+	var wg sync.WaitGroup
+	wg.Add(handlerWorkers)
+	for i := 0; i < handlerWorkers; i++ {
+		go func() {
+			defer wg.Done()
+			for m := range n.work {
+				n.handler(m)
+			}
+		}()
+	}
+	return func() {
+		close(n.work)
+		wg.Wait()
+		n.work = nil
+	}
+}
+
+// dispatch hands m to the handler, either directly or through the worker pool. If all workers are busy, dispatch blocks until one is free; this slows the receive loop down rather than queueing up an unlimited number of messages.
+func (n *Node) dispatch(m Message) {
+	switch {
+	case n.handler == nil:
+	case n.work != nil:
+		n.work <- m
+	default:
+		n.handler(m)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/go-mangos/mangos"
+)
+
+// Container orchestrators like Kubernetes decide whether a process is ready, or still alive, by probing it. With `-health-addr`, a node serves `GET /healthz` for such probes: the answer is 200 OK while the node has at least one connection to a peer, and 503 Service Unavailable otherwise. A PAIR node that has lost its peer thus shows up as not ready until Mangos has reconnected.
+
+// trackConnection is a port hook that counts the node's connections. Like limitPeers, it must run after all hooks that may reject a connection.
+func (n *Node) trackConnection(action mangos.PortAction, port mangos.Port) bool {
+	switch action {
+	case mangos.PortActionAdd:
+		atomic.AddInt32(&n.connections, 1)
+	case mangos.PortActionRemove:
+		atomic.AddInt32(&n.connections, -1)
+	}
+	return true
+}
+
+// healthz answers health probes.
+func (n *Node) healthz(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if atomic.LoadInt32(&n.connections) == 0 {
+		http.Error(w, "no connected peer", http.StatusServiceUnavailable)
+		return
+	}
+	w.Write([]byte("ok\n"))
+}
+
+// serveHealth serves `/healthz` on addr in the background until ctx is done. Like the metrics server, a health server that fails only gets logged.
+func (n *Node) serveHealth(ctx context.Context, addr string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", n.healthz)
+	srv := &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+	go func() {
+		err := srv.ListenAndServe()
+		if err != http.ErrServerClosed {
+			logError("Cannot serve health checks on '%s': %s\n", addr, err.Error())
+		}
+	}()
+}
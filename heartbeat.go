@@ -0,0 +1,46 @@
+package main
+
+import (
+	"time"
+
+	"github.com/go-mangos/mangos"
+)
+
+// heartbeatInterval is the time between two keepalive messages. Zero disables the keepalive.
+var heartbeatInterval time.Duration
+
+// heartbeat sends a small keepalive message every interval until done gets closed.
+//
+// A connection can die silently, for example when a NAT router in between forgets about it. The node only notices when it tries to send something, and on a quiet connection, this may take a long time. Heartbeats make sure that something gets sent regularly, so Mangos detects a dead connection early and reconnects.
+//
+// The receiving side discards heartbeats (see `Receive()`), so they do not count as messages.
+func (n *Node) heartbeat(interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		payload, err := pack(Message{From: n.Name, Heartbeat: true, SentAt: time.Now()})
+		if err == nil {
+			err = n.socket.Send(payload)
+		}
+		if err == mangos.ErrClosed {
+			return
+		}
+		if err != nil {
+			logError("Node %s failed to send a heartbeat: %s\n", n.Name, err.Error())
+		}
+	}
+}
+
+// startHeartbeat starts the heartbeat goroutine if heartbeats are enabled. Calling the returned function stops it again.
+func (n *Node) startHeartbeat() (stop func()) {
+	done := make(chan struct{})
+	if heartbeatInterval > 0 {
+		go n.heartbeat(heartbeatInterval, done)
+	}
+	return func() { close(done) }
+}
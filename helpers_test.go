@@ -0,0 +1,83 @@
+package main
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testTimeout is the receive deadline of the test nodes. It is long enough for a slow CI machine, and short enough that a test that waits in vain fails soon.
+const testTimeout = 2 * time.Second
+
+// testURL returns an inproc URL that belongs to the calling test alone.
+func testURL(t *testing.T, suffix string) string {
+	return "inproc://" + strings.Replace(t.Name(), "/", "-", -1) + suffix
+}
+
+// newTestNode creates a node with a PAIR socket. The caller closes the socket when done, preferably with `defer closeNodes(...)`.
+func newTestNode(t *testing.T, name string) *Node {
+	t.Helper()
+	n := newNode(name)
+	socket, err := n.newSocket(testTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.socket = socket
+	return n
+}
+
+// closeNodes closes the sockets of the test nodes. Closing a socket twice does no harm, so a test may also close a socket early, say, to simulate a peer that goes away.
+func closeNodes(nodes ...*Node) {
+	for _, n := range nodes {
+		n.socket.Close()
+	}
+}
+
+// listenAndDial lets l listen on url and d dial it, and waits until they are connected.
+func listenAndDial(t *testing.T, l, d *Node, url string) {
+	t.Helper()
+	connected := onConnect(l.socket)
+	if err := listen(l.socket, url); err != nil {
+		t.Fatalf("cannot listen on %s: %s", url, err)
+	}
+	if err := dial(d.socket, url); err != nil {
+		t.Fatalf("cannot dial %s: %s", url, err)
+	}
+	select {
+	case <-connected:
+	case <-time.After(testTimeout):
+		t.Fatalf("no connection on %s", url)
+	}
+}
+
+// newTestPair returns two PAIR nodes that are connected over inproc. As with newTestNode, the caller closes them.
+func newTestPair(t *testing.T) (l, d *Node) {
+	t.Helper()
+	l, d = newTestNode(t, "listener"), newTestNode(t, "dialer")
+	listenAndDial(t, l, d, testURL(t, ""))
+	return l, d
+}
+
+// expectBody receives a message on n and fails the test unless its body is want.
+func expectBody(t *testing.T, n *Node, want string) {
+	t.Helper()
+	m, err := n.Receive()
+	if err != nil {
+		t.Fatalf("%s did not receive '%s': %s", n.Name, want, err)
+	}
+	if m.Body != want {
+		t.Fatalf("%s received '%s', want '%s'", n.Name, m.Body, want)
+	}
+}
+
+// freeAddr returns a loopback address with a port that nobody listens on, for the few tests that need a real network connection.
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().String()
+}
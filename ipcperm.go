@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/go-mangos/mangos"
+)
+
+// ipcPerm is the file mode for the socket file of an ipc listener. Zero keeps the mode that the system creates the file with, which depends on the umask and may allow every local user to connect.
+var ipcPerm os.FileMode
+
+// listen adds the transport for the URL and makes the socket listen on it. If an ipc socket file from a crashed node is in the way, listen removes it and tries again (see `ipcstale.go`). For ipc URLs, it then restricts the socket file to ipcPerm. If this fails, listen closes the listener again rather than leaving a socket with the wrong permissions around.
+func listen(socket mangos.Socket, url string) error {
+	err := addTransportForURL(socket, url)
+	if err != nil {
+		return err
+	}
+	l, err := socket.NewListener(url, transportOptions(url))
+	if err != nil {
+		return err
+	}
+	err = l.Listen()
+	if err != nil && removeStaleIPC(url, err) {
+		err = l.Listen()
+	}
+	if err != nil {
+		return err
+	}
+	if ipcPerm == 0 || !strings.HasPrefix(url, "ipc://") {
+		return nil
+	}
+	path := strings.TrimPrefix(url, "ipc://")
+	if err = os.Chmod(path, ipcPerm); err != nil {
+		l.Close()
+		return fmt.Errorf("cannot set permissions of '%s': %s", path, err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// An ipc listener creates a socket file, and removes it again when it closes. If a node crashes, the file stays behind, and the next node that wants to listen on the same path fails with "address already in use", although nobody uses the address.
+//
+// removeStaleIPC checks whether a failed listen on an ipc URL was caused by such a stale socket file, and if so, removes the file. It returns true if the caller should try again.
+//
+// The file may also belong to a node that is alive and well. To tell the two cases apart, removeStaleIPC tries to connect to the file. A live listener accepts the connection, and the file stays. Only if the connection gets refused, nobody listens, and the file is safe to remove. Files that are no sockets at all are never removed.
+func removeStaleIPC(url string, listenErr error) bool {
+	if !strings.HasPrefix(url, "ipc://") || !errors.Is(listenErr, syscall.EADDRINUSE) {
+		return false
+	}
+	path := strings.TrimPrefix(url, "ipc://")
+	info, err := os.Stat(path)
+	if err != nil || info.Mode()&os.ModeSocket == 0 {
+		return false
+	}
+	conn, err := net.DialTimeout("unix", path, time.Second)
+	if err == nil {
+		conn.Close()
+		return false
+	}
+	if !errors.Is(err, syscall.ECONNREFUSED) {
+		return false
+	}
+	if err = os.Remove(path); err != nil {
+		return false
+	}
+	logInfo("Removed the stale socket file '%s'\n", path)
+	return true
+}
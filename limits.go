@@ -0,0 +1,20 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrMessageTooLarge is returned when a payload exceeds the configured maximum send size.
+var ErrMessageTooLarge = errors.New("message too large")
+
+// maxSendSize is the largest payload in bytes that a node attempts to send. Zero means no limit.
+var maxSendSize int
+
+// checkSendSize fails with ErrMessageTooLarge if the payload is larger than maxSendSize. A peer that limits its receive size would otherwise just drop the connection, which is much harder to diagnose than a clear error on the sending side.
+func checkSendSize(payload []byte) error {
+	if maxSendSize > 0 && len(payload) > maxSendSize {
+		return fmt.Errorf("%w: %d bytes exceed the limit of %d bytes", ErrMessageTooLarge, len(payload), maxSendSize)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Logger is what the nodes need for logging: a `Printf` method. A `*log.Logger` satisfies this interface, so callers can pass one that writes to a buffer or to a file.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// logger receives all log output except fatal errors, which still go through `log.Fatalf()` as they end the process anyway. By default, it writes to stderr, just like the standard `log` functions.
+var logger Logger = log.New(os.Stderr, "", log.LstdFlags)
+
+// Not every log line is equally important. A long run with hundreds of messages per second buries the one line that matters under a heap of "sends" and "received" lines. So each log line has a level, and `-v` and `-q` choose which levels get through:
+//
+// * levelError only logs failures and warnings, with `-q`.
+// * levelInfo also logs what happens to the node, like connections, and summaries like "Done." This is the default.
+// * levelDebug also logs every message that the node sends or receives, with its full body, with `-v`.
+//
+// The levels sit in front of the Logger, so a custom Logger, or the JSON logger, gets just the lines of the chosen levels.
+type logLevel int
+
+const (
+	levelError logLevel = iota
+	levelInfo
+	levelDebug
+)
+
+// level is the highest level that gets logged.
+var level = levelInfo
+
+// logError logs a failure or a warning. These get logged at every level.
+func logError(format string, v ...interface{}) {
+	logger.Printf(format, v...)
+}
+
+// logInfo logs what happens to the node, unless `-q` is set.
+func logInfo(format string, v ...interface{}) {
+	if level >= levelInfo {
+		logger.Printf(format, v...)
+	}
+}
+
+// An Event is something that happened to a node, like sending a message or a new connection. A human reads the log line; a log aggregator would rather get the details as separate fields.
+type Event struct {
+	Time  time.Time `json:"ts"`
+	Node  string    `json:"node,omitempty"`
+	Event string    `json:"event"`
+	Seq   int       `json:"seq,omitempty"`
+	Peer  string    `json:"peer,omitempty"`
+	Topic string    `json:"topic,omitempty"`
+	Body  string    `json:"body,omitempty"`
+	// Msg is the log line of events that have no fields of their own, like errors.
+	Msg string `json:"msg,omitempty"`
+}
+
+// eventLogger is a Logger that also takes events.
+type eventLogger interface {
+	Event(e Event)
+}
+
+// logEvent hands e to the logger if it takes events, and logs the formatted line otherwise. Events are of the level levelInfo.
+func logEvent(e Event, format string, v ...interface{}) {
+	if level < levelInfo {
+		return
+	}
+	if l, ok := logger.(eventLogger); ok {
+		e.Time = time.Now()
+		l.Event(e)
+		return
+	}
+	logger.Printf(format, v...)
+}
+
+// jsonLogger writes one JSON object per line, for `-json-logs`. Events keep their fields; plain log lines become events of type "log". As nearly all log lines start with "Node %s", jsonLogger moves the node name from such a line into the node field.
+type jsonLogger struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// newJSONLogger creates a jsonLogger that writes to w.
+func newJSONLogger(w io.Writer) *jsonLogger {
+	return &jsonLogger{enc: json.NewEncoder(w)}
+}
+
+// Event writes e as a line of JSON. The sending and the receiving goroutine log at the same time, so a mutex keeps their lines apart.
+func (l *jsonLogger) Event(e Event) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.enc.Encode(e)
+}
+
+func (l *jsonLogger) Printf(format string, v ...interface{}) {
+	e := Event{Time: time.Now(), Event: "log"}
+	if strings.HasPrefix(format, "Node %s") && len(v) > 0 {
+		e.Node = fmt.Sprint(v[0])
+		format, v = strings.TrimPrefix(strings.TrimPrefix(format, "Node %s"), ":"), v[1:]
+	}
+	e.Msg = strings.TrimSpace(fmt.Sprintf(format, v...))
+	l.Event(e)
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+)
+
+// Any `*log.Logger` can take the log output, so a test can capture it in a buffer.
+func TestLoggerCapturesOutput(t *testing.T) {
+	defer func(l Logger, lv logLevel) { logger, level = l, lv }(logger, level)
+	var buf bytes.Buffer
+	logger, level = log.New(&buf, "", 0), levelInfo
+
+	logInfo("Node %s: Done.\n", "node1")
+	logMessage(Event{Node: "node1", Event: "send", Body: "hello"}, "Node %s sends %s\n", "node1", "hello")
+
+	if got := buf.String(); got != "Node node1: Done.\n" {
+		t.Errorf("got %q, want only the info line", got)
+	}
+	level = levelDebug
+	logMessage(Event{Node: "node1", Event: "send", Body: "hello"}, "Node %s sends %s\n", "node1", "hello")
+	if !strings.Contains(buf.String(), "Node node1 sends hello") {
+		t.Errorf("debug line missing at levelDebug: %q", buf.String())
+	}
+}
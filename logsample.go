@@ -0,0 +1,18 @@
+package main
+
+import (
+	"math/rand"
+)
+
+// logSample is the fraction of per-message events that get logged. Errors and lifecycle events are always logged.
+var logSample = 1.0
+
+// logMessage logs a per-message event, like sending or receiving a message. These events only get logged with `-v` (see `logger.go`). At high message rates, set logSample to a small value to log only a representative subset of these events.
+func logMessage(e Event, format string, v ...interface{}) {
+	if level < levelDebug {
+		return
+	}
+	if logSample >= 1 || rand.Float64() < logSample {
+		logEvent(e, format, v...)
+	}
+}
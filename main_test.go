@@ -0,0 +1,18 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"log"
+	"os"
+	"testing"
+)
+
+// The nodes log a lot, which would bury the test output. As logger takes any `*log.Logger`, the tests just pass one that discards everything. Run the tests with `-v` to see the log anyway.
+func TestMain(m *testing.M) {
+	flag.Parse()
+	if !testing.Verbose() {
+		logger = log.New(ioutil.Discard, "", 0)
+	}
+	os.Exit(m.Run())
+}
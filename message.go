@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"sync/atomic"
+	"time"
+)
+
+// Message is the envelope that nodes exchange. Besides the actual message text in Body, it tells the receiver who sent the message, and when.
+//
+// Heartbeat marks keepalive messages, which carry no body and no sequence number (see `heartbeat.go`). ID is a correlation id that a reply shares with its request (see `reqrep.go`). Ack marks acknowledgements, whose Seq is the sequence number of the acknowledged message (see `reliable.go`).
+type Message struct {
+	From      string    `json:"from"`
+	Seq       int       `json:"seq"`
+	Body      string    `json:"body"`
+	SentAt    time.Time `json:"sent_at"`
+	Heartbeat bool      `json:"heartbeat,omitempty"`
+	ID        string    `json:"id,omitempty"`
+	Ack       bool      `json:"ack,omitempty"`
+}
+
+// newMessage wraps body in an envelope with the next sequence number.
+func (n *Node) newMessage(body string) Message {
+	return Message{
+		From:   n.Name,
+		Seq:    int(atomic.AddInt64(&n.lastSeq, 1)),
+		Body:   body,
+		SentAt: time.Now(),
+	}
+}
+
+// encodeJSON serializes a message. JSON is not the most compact format, but it is easy to read, and every language has a JSON library.
+func encodeJSON(m Message) ([]byte, error) {
+	return json.Marshal(m)
+}
+
+// decodeJSON deserializes a message.
+func decodeJSON(b []byte) (Message, error) {
+	var m Message
+	err := json.Unmarshal(b, &m)
+	return m, err
+}
+
+// encodeGob serializes a message with gob. Only Go programs can read gob, and it is not automatically the faster choice: gob shines on a stream of values, where it describes the type only once. Every message here gets encoded on its own, so each one carries the type description, and for our small envelope this costs more than JSON saves. Run `go test -bench Encode` to compare the two on your machine.
+func encodeGob(m Message) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(m)
+	return buf.Bytes(), err
+}
+
+// decodeGob deserializes a gob-encoded message.
+func decodeGob(b []byte) (Message, error) {
+	var m Message
+	err := gob.NewDecoder(bytes.NewReader(b)).Decode(&m)
+	return m, err
+}
+
+// codec is a pair of functions for serializing and deserializing messages.
+type codec struct {
+	encode func(Message) ([]byte, error)
+	decode func([]byte) (Message, error)
+}
+
+// codecs maps codec names to codecs.
+var codecs = map[string]codec{
+	"json":     {encodeJSON, decodeJSON},
+	"gob":      {encodeGob, decodeGob},
+	"protobuf": {encodeProto, decodeProto},
+}
+
+// msgCodec is the codec that pack and unpack use. Both peers must use the same codec.
+var msgCodec = codecs["json"]
+
+// pack turns a message into the payload that goes over the wire: it runs the message body through the send chain, which starts with msgCodec and continues with the middlewares like compression and encryption (see `middleware.go`), and checks that the result does not exceed the maximum send size.
+func pack(m Message) ([]byte, error) {
+	payload, err := applySend([]byte(m.Body), encodeEnvelope(msgCodec, m))
+	if err != nil {
+		return nil, &Error{Class: ErrEncode, Err: err}
+	}
+	err = checkSendSize(payload)
+	if err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// unpack reverses pack. Where pack fails with an error of the class ErrEncode, unpack fails with one of the class ErrDecode (see `errors.go`).
+func unpack(payload []byte) (Message, error) {
+	var m Message
+	if _, err := applyRecv(payload, decodeEnvelope(msgCodec, &m)); err != nil {
+		return Message{}, &Error{Class: ErrDecode, Err: err}
+	}
+	return m, nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// testMessage has every field set, so that a codec that drops a field fails the round trip.
+var testMessage = Message{
+	From:      "node1",
+	Seq:       42,
+	Body:      "Hello, world!",
+	SentAt:    time.Date(2017, 3, 14, 15, 9, 26, 535897932, time.UTC),
+	Heartbeat: true,
+	ID:        "node1-42",
+	Ack:       true,
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	b, err := encodeJSON(testMessage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := decodeJSON(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m != testMessage {
+		t.Errorf("got %+v, want %+v", m, testMessage)
+	}
+}
+
+func TestGobRoundTrip(t *testing.T) {
+	b, err := encodeGob(testMessage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := decodeGob(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !m.SentAt.Equal(testMessage.SentAt) {
+		t.Errorf("SentAt: got %v, want %v", m.SentAt, testMessage.SentAt)
+	}
+	m.SentAt = testMessage.SentAt
+	if m != testMessage {
+		t.Errorf("got %+v, want %+v", m, testMessage)
+	}
+}
+
+// benchmarkCodec encodes and decodes testMessage b.N times, and reports the size of the encoded message.
+func benchmarkCodec(b *testing.B, c codec) {
+	payload, err := c.encode(testMessage)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		payload, err := c.encode(testMessage)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := c.decode(payload); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(len(payload)), "bytes/msg")
+}
+
+func BenchmarkEncodeJSON(b *testing.B) { benchmarkCodec(b, codecs["json"]) }
+
+func BenchmarkEncodeGob(b *testing.B) { benchmarkCodec(b, codecs["gob"]) }
@@ -182,101 +182,577 @@ If everything is ok, we can move forward to creating a sample PAIR implementatio
 
 // First, we import Mangos. Note that you need to explicitly import (a) the Scalability Protocol, and (b) the transport(s) that the protocol shall use.
 //
-// For this example, we import the PAIR protocol and the ipc and tcp transports.
+// For this example, we import the PAIR protocol here. The ipc, tcp, ws (WebSocket), and inproc transports are imported in `transport.go`.
 //
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-mangos/mangos"
 	"github.com/go-mangos/mangos/protocol/pair"
-	"github.com/go-mangos/mangos/transport/ipc"
-	"github.com/go-mangos/mangos/transport/tcp"
 )
 
-// Our sample program shall run as either "node 0" or "node 1". A global variable is just fine for this purpose.
-var (
-	node string
-)
+// Our sample program shall run as either "node 0" or "node 1". A node has a name, a socket, and some bookkeeping of its own, so we keep all of this in a `Node` struct. As a node does not depend on any global state, a process can also run several nodes side by side, like the self-test does (see `selftest.go`).
+type Node struct {
+	// lastSeq is the sequence number of the message that this node has sent last (see `message.go`). It goes first, as 64-bit atomic operations need an aligned field on 32-bit platforms.
+	lastSeq int64
+	// Name identifies the node in log messages, and in the From field of its messages.
+	Name   string
+	socket mangos.Socket
+	// sequences tracks the sequence numbers of the messages from the peers, if the node has a use for it (see `sequence.go`).
+	sequences *sequenceTracker
+	// acks passes incoming ACKs to the sender that waits for them (see `reliable.go`).
+	acks chan int
+	// dedup holds the ids of the recently received messages, if the node drops duplicates (see `dedup.go`).
+	dedup *dedupSet
+	// handler handles the received messages, and work feeds the workers that run the handler (see `handler.go`).
+	handler func(Message)
+	work    chan Message
+	// pending delivers the result of a Receive() call that receiveCtx has stopped waiting for (see `cancel.go`).
+	pending chan receiveResult
+	// queue holds the messages that wait for the sender goroutine, if the node uses a send queue (see `queue.go`).
+	queue chan string
+	// connections counts all connections of the node, accepted or dialed (see `health.go`).
+	connections int32
+	// peers counts the connections that the node currently has accepted (see `access.go`).
+	peers struct {
+		sync.Mutex
+		n int
+	}
+}
+
+// newNode creates a node without a socket. Each kind of node creates the socket that it needs when it runs.
+func newNode(name string) *Node {
+	return &Node{Name: name, acks: make(chan int, 16)}
+}
 
 // Now we are ready to create our first socket. Note the use of the `pair` package. Our new socket will therefore automatically support the PAIR protocol.
-func newSocket() mangos.Socket {
+//
+// The timeout parameter sets the receive deadline; see below.
+//
+// If the socket cannot be created, `newSocket()` returns the error rather than ending the process, so that the caller can decide what to do.
+func (n *Node) newSocket(timeout time.Duration) (mangos.Socket, error) {
 	socket, err := pair.NewSocket()
 	if err != nil {
-		log.Fatalf("Node %s: Cannot create socket: %s\n", node, err.Error())
-	}
-	// Here we add IPC and TCP transports. Later, Listen() and Dial() can then use either of these transports.
-	socket.AddTransport(ipc.NewTransport())
-	socket.AddTransport(tcp.NewTransport())
-	// Set a deadline of 10 seconds for receiving a message. If the socket does not receive a message within that time, it errors out.
-	socket.SetOption(mangos.OptionRecvDeadline, 10*time.Second)
-	return socket
+		return nil, fmt.Errorf("cannot create PAIR socket: %w", err)
+	}
+	n.setupSocket(socket, timeout)
+	return socket, nil
 }
 
-//Next, we implement a `send()` function that sends a simple string as the message.
-//
-// Looks quite easy, doesn't it? We just do a `socket.Send(...)` here, with some additional logging and error handling. The Socket's `Send()` method expects a `[]byte` parameter, but converting from `string` to `[]byte` is straightforward.
+// By default, a node finds its role by itself: it dials a URL only if it cannot listen on it. forceListen and forceDial override this.
+var (
+	forceListen bool
+	forceDial   bool
+)
+
+// When a dialing socket loses its connection, for example because the peer restarts, Mangos redials automatically. The first attempt happens after reconnectTime; after each failed attempt, the interval doubles until it reaches maxReconnectTime.
+var (
+	reconnectTime    = 100 * time.Millisecond
+	maxReconnectTime = 10 * time.Second
+)
+
+// sendTimeout limits how long `Send()` may block when the send buffer is full, for example because the peer does not read its messages, or the link is slow. Zero means that `Send()` waits as long as it takes.
+var sendTimeout time.Duration
+
+// linger is how long `Close()` waits for the messages in the send buffer to go out. Without lingering, a node that sends its last message and closes the socket right away may drop that message before it has reached the peer. Mangos lingers one second by default.
+var linger = time.Second
+
+// Between the application and the connections, each socket has a write queue and a read queue, which buffer 128 messages each by default. When a node sends a burst of messages that is larger than the write queue, `Send()` blocks until the connection has taken enough messages out of the queue; likewise, a full read queue stops the connection from reading more messages from the peer. Larger queues absorb larger bursts, at the price of more memory, and of more messages that a node loses when it goes down. Set them with `-write-qlen` and `-read-qlen`; `bench -burst` shows the effect. Zero keeps the defaults.
+var (
+	writeQLen int
+	readQLen  int
+)
+
+// ttl is the number of hops that a message may travel in a topology where nodes forward messages to each other. Each node that forwards a message counts up its hop count, and a node drops a message whose hop count has reached its own TTL, so a loop in the topology cannot keep a message circling forever. Set it with `-ttl`, from 1 to 255. Zero keeps the Mangos default of 8.
 //
-// For sending more complex messages, the sending process needs to serialize your message into a []byte slice, and the receiving process needs to de-serialize the slice again. While serializing and de-serializing is not terribly complex, we do not look into this right now as we want to keep this example as simple as possible.
-func send(socket mangos.Socket, message string) {
-	log.Printf("Node %s sends %s\n", node, message)
-	err := socket.Send([]byte(message))
-	if err != nil {
-		log.Fatalf("Node %s failed to send '%s': %s\n", node, message, err.Error())
+// Only the protocols that forward messages know a TTL: STAR, and REP, RESPONDENT, and SURVEYOR when they run behind a device. A BUS socket, in the version of Mangos that we use, never forwards a message; it delivers each message only to its direct peers, so a BUS mesh cannot loop, and the other sockets simply ignore the option.
+var ttl int
+
+// maxMsgSize is the largest message in bytes that a socket accepts. Zero means no limit.
+var maxMsgSize = 1024 * 1024
+
+// All of our sockets, whatever protocol they implement, get the same transports and options.
+func (n *Node) setupSocket(socket mangos.Socket, timeout time.Duration) {
+	// Note that we do not add any transports here. Rather, `listen()` and `dial()` add the one transport that each URL needs, right before listening or dialing (see `transport.go`).
+	// Set a deadline for receiving a message. If the socket does not receive a message within that time, it errors out. The default is 10 seconds, which can be changed with the `-recv-timeout` option. A timeout of zero disables the deadline, and the socket waits forever.
+	socket.SetOption(mangos.OptionRecvDeadline, timeout)
+	// Likewise, a send deadline makes a blocked `Send()` give up with `mangos.ErrSendTimeout` rather than hang. Set it with the `-send-timeout` option.
+	socket.SetOption(mangos.OptionSendDeadline, sendTimeout)
+	// Give pending messages the time to go out when the socket gets closed. Set it with the `-linger` option.
+	socket.SetOption(mangos.OptionLinger, linger)
+	// The queue lengths cannot change anymore once the socket listens or dials, so they must be set right here.
+	if writeQLen > 0 {
+		socket.SetOption(mangos.OptionWriteQLen, writeQLen)
+	}
+	if readQLen > 0 {
+		socket.SetOption(mangos.OptionReadQLen, readQLen)
+	}
+	// Bound the hops of forwarded messages. Sockets whose protocol has no TTL reject the option with mangos.ErrBadOption, which is fine.
+	if ttl > 0 {
+		socket.SetOption(mangos.OptionTTL, ttl)
 	}
+	// Limit the size of incoming messages, so that a misbehaving peer cannot make us allocate arbitrary amounts of memory. When a peer sends a larger message, Mangos drops the connection to this peer rather than reading the message. The receiver then sees no error but just no message, until the receive deadline passes.
+	socket.SetOption(mangos.OptionMaxRecvSize, maxMsgSize)
+	// Configure the automatic reconnect. These options must be set before dialing.
+	socket.SetOption(mangos.OptionReconnectTime, reconnectTime)
+	socket.SetOption(mangos.OptionMaxReconnectTime, maxReconnectTime)
+	// The port hook gets called whenever a peer connects or disconnects. We use it to turn away peers that are not in the allow list, to limit the number of peers, to log connects and disconnects with `-log-connections`, and to count the connections for health checks (see `health.go`).
+	socket.SetPortHook(portHooks(n.allowPeer, n.limitPeers, n.logConnection, n.trackConnection))
 }
 
-// The receiving end should now be self-documenting.
-func receive(socket mangos.Socket) string {
-	// Remember the deadline option we have set for the socket? When `socket.Recv()` does not receive anything for 10 seconds, it returns an error that the `receive()` function turns into a `log.Fatalf()` message. Keep in mind that the `Fatal...()` methods of Go's standard log package exit the process immediately after writing the log message. Real-life code would do some more sophisticated error handling here of course.
-	bytes, err := socket.Recv()
+//Next, we implement a `send()` method that sends a simple string as the message.
+//
+// Looks quite easy, doesn't it? We just do a `socket.Send(...)` here, with some additional logging and error handling. The Socket's `Send()` method expects a `[]byte` parameter, so we need to turn our message into a byte slice first.
+//
+// We could just convert the string to `[]byte`, but real-life messages usually carry more than just some text. For sending more complex messages, the sending process needs to serialize your message into a []byte slice, and the receiving process needs to de-serialize the slice again. Our messages therefore travel inside a small `Message` envelope that also tells the sender and the time of sending. `pack()` serializes the envelope to JSON, or with `-codec`, to gob or protobuf (see `message.go`).
+func (n *Node) send(message string) {
+	err := n.Send(message)
 	if err != nil {
-		log.Fatalf("Node %s failed receiving a message: %s\n", node, err.Error())
+		log.Fatalf("Node %s failed to send '%s': %s\n", n.Name, message, err.Error())
 	}
-	message := string(bytes)
-	log.Printf("Node %s received %s\n", node, message)
-	return message
 }
 
-// Now let's start implementing the behavior of our two nodes. We want nothing sophisticated, so we let the two nodes just send three messages to each other.
+// `Send()` does the actual sending. Unlike `send()`, it hands errors back to the caller.
+func (n *Node) Send(message string) error {
+	return n.sendMessage(n.newMessage(message))
+}
 
-func runNode(url string) {
-	// The code first calls our `newSocket` function that we defined earlier.
-	socket := newSocket()
-	// Then the process tries to listen on the socket.
-	err := socket.Listen(url)
-	//  If it fails, then this means that the other process was faster. In this case the process instead dials the socket.
-	if err != nil {
-		log.Printf("Node %s cannot listen on socket '%s': %s\nTrying to dial instead\n", node, url, err.Error())
-		err = socket.Dial(url)
+// `sendMessage()` sends a complete envelope, for callers that need to fill in more than the body.
+func (n *Node) sendMessage(m Message) error {
+	logMessage(Event{Node: n.Name, Event: "send", Seq: m.Seq, Body: m.Body}, "Node %s sends %s\n", n.Name, m.Body)
+	payload, err := pack(m)
+	if err == nil {
+		err = n.socket.Send(payload)
+	}
+	stats.countSend(err)
+	return classify(err)
+}
+
+// The receiving end should now be self-documenting. `unpack()` restores the `Message` from the bytes that `pack()` produced on the sending side.
+//
+// Remember the deadline option we have set for the socket? When `socket.Recv()` does not receive anything before the deadline, it returns `mangos.ErrRecvTimeout`. Rather than exiting the process right away, `Receive()` hands this error (and any other one) back to the caller, wrapped in the class ErrTimeout (see `errors.go`), who can then decide whether a timeout is worth a retry or whether the connection is broken for good.
+//
+// There is one more error that is not a failure: once the socket gets closed, `socket.Recv()` returns `mangos.ErrClosed`, and so does a call that was already waiting. A closed socket means that the node is shutting down, so the receive loops stop quietly on this error, just like on a receive timeout, instead of exiting with a fatal error.
+//
+// Messages whose body does not pass the filter set with `-filter` are dropped, and `Receive()` waits for the next one.
+func (n *Node) Receive() (Message, error) {
+	// Heartbeats and ACKs are no messages, so they must not keep the receive timeout from expiring.
+	var deadline time.Time
+	if timeout, err := n.socket.GetOption(mangos.OptionRecvDeadline); err == nil && timeout.(time.Duration) > 0 {
+		deadline = time.Now().Add(timeout.(time.Duration))
+	}
+	for {
+		payload, err := n.socket.Recv()
 		if err != nil {
-			log.Fatalf("Node %s can neither listen nor dial on socket '%s': %s\n", node, url, err.Error())
+			stats.countRecv(err)
+			return Message{}, classify(err)
 		}
+		m, err := unpack(payload)
+		if err != nil {
+			return Message{}, err
+		}
+		if m.Ack {
+			n.handleAck(m)
+		}
+		if m.Heartbeat || m.Ack {
+			if !deadline.IsZero() && time.Now().After(deadline) {
+				stats.countRecv(mangos.ErrRecvTimeout)
+				return Message{}, classify(mangos.ErrRecvTimeout)
+			}
+			continue
+		}
+		// In BUS networks, a message may arrive more than once (see `dedup.go`).
+		if n.isDuplicate(m) {
+			continue
+		}
+		n.checkSequence(m)
+		if reliable {
+			n.acknowledge(m)
+		}
+		if !accept([]byte(m.Body)) {
+			continue
+		}
+		if payloadSize > 0 {
+			if err := checkPayload(m.Body, payloadSize); err != nil {
+				logError("Node %s: Warning: message %d from %s: %s\n", n.Name, m.Seq, m.From, err.Error())
+			}
+		}
+		logMessage(Event{Node: n.Name, Event: "receive", Seq: m.Seq, Peer: m.From, Body: m.Body}, "Node %s received %s\n", n.Name, m.Body)
+		// With `-capture`, the message also goes to the capture file (see `capture.go`).
+		captured.record(m, "")
+		stats.countRecv(nil)
+		return m, nil
+	}
+}
+
+// Now let's start implementing the behavior of our two nodes. We want nothing sophisticated, so we let the two nodes just send three messages to each other.
+//
+// A node can also get more than one URL, for example to connect to a peer over several addresses. The node then listens on the first URL that it can listen on, and dials all others.
+func (n *Node) Run(ctx context.Context, urls []string, timeout time.Duration) {
+	// The code first calls our `newSocket` method that we defined earlier. Without a socket, there is nothing a node can do, so it gives up.
+	socket, err := n.newSocket(timeout)
+	if err != nil {
+		log.Fatalf("Node %s: %s\n", n.Name, err.Error())
 	}
+	n.socket = socket
+	n.sequences = newSequenceTracker()
 	// In any case, we ensure the socket gets closed at the end of the function.
 	defer socket.Close()
+	connected := 0
+	listening := false
+	for _, url := range urls {
+		// Then the process tries to listen on the socket, unless it already listens on one of the other URLs.
+		//
+		// The "try to listen, then dial" dance can be confusing when debugging, so the user can also pin the node's role with `-listen` or `-dial`.
+		if !forceDial && (forceListen || !listening) {
+			err := listen(socket, url)
+			if err == nil {
+				listening = true
+				connected++
+				continue
+			}
+			if forceListen {
+				logError("Node %s cannot listen on socket '%s': %s\n", n.Name, url, err.Error())
+				continue
+			}
+			//  If it fails, then this means that the other process was faster. In this case the process instead dials the socket.
+			logInfo("Node %s cannot listen on socket '%s': %s\nTrying to dial instead\n", n.Name, url, err.Error())
+		}
+		err := dialWithRetry(socket, url, dialAttempts, dialBackoff)
+		if err != nil {
+			// A URL that fails is no reason to give up, as long as the other URLs work.
+			logError("Node %s can neither listen nor dial on socket '%s': %s\n", n.Name, url, err.Error())
+			continue
+		}
+		connected++
+	}
+	if connected == 0 {
+		log.Fatalf("Node %s: None of the URLs works\n", n.Name)
+	}
+	// With `-once`, the node sends one message and is done (see `once.go`). If that fails, the process exits with an error; closing the socket first gives the message the time to go out (see `-linger`).
+	if sendOnce {
+		if err := n.once(ctx, timeout); err != nil {
+			socket.Close()
+			log.Fatalf("Node %s: %s\n", n.Name, err.Error())
+		}
+		return
+	}
+	// With `-heartbeat`, the node sends keepalive messages in the background.
+	defer n.startHeartbeat()()
+
+	// Now the two processes should have found their role as the listening or the dialing part. What they do next depends on the `-mode-duplex` option. By default, they send and receive at the same time. The other modes let them play ping-pong, or turn a node into a pure producer or a pure consumer; see `duplex.go`.
+	switch duplexMode {
+	case "pingpong":
+		n.pingPong(ctx)
+	case "fire-forget":
+		n.fireAndForget(ctx)
+	case "receive-only":
+		n.receiveOnly(ctx)
+	default:
+		n.duplex(ctx)
+	}
+	if messageFilter != nil {
+		logInfo("Node %s dropped %d messages that did not match the filter\n", n.Name, atomic.LoadUint64(&dropped))
+	}
+	logInfo("Node %s: Done.\n", n.Name)
+}
+
+// This is Exercise 2 from the end of the article: Sending and receiving run in two goroutines of their own, so neither has to wait for the other. A `sync.WaitGroup` lets `duplex()` wait until both are done.
+//
+// Both goroutines also watch the context, which gets cancelled when the user hits Ctrl-C. This way, the node shuts down right away instead of waiting out the receive deadline.
+func (n *Node) duplex(ctx context.Context) {
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		n.sendLoop(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		n.receiveLoop(ctx)
+	}()
+	wg.Wait()
+}
+
+// messageCount is the number of messages that a PAIR node sends. A negative count means that the node sends until it gets interrupted, which turns the node into a simple load generator, or a long-running connectivity test.
+var messageCount = 3
+
+// sendRate is the number of messages per second that the send loop sends. Zero means as fast as possible.
+var sendRate = 1.0
+
+// The sender sends three messages, or as many as the `-count` option says. By default, it sends one message per second, for a more dramatic effect in your terminal. The `-rate` option changes the pace, for demos or load tests.
+//
+// A ticker keeps the pace. Unlike sleeping after each message, a ticker does not add the time for sending to the interval. The ticker must be stopped when the loop ends, or it would keep ticking in the background.
+//
+// Before each message, the node checks if an operator has paused it (see `pause.go`).
+//
+// With `-stdin`, the messages come from standard input instead (see `stdin.go`), and with `-replay`, they come from a file (see `replay.go`).
+//
+// With `-send-queue`, the loop only enqueues the messages, and the sender goroutine sends them (see `queue.go`). If the queue is full, the message gets dropped.
+func (n *Node) sendLoop(ctx context.Context) {
+	if readStdin {
+		n.sendStdin(ctx)
+		return
+	}
+	if replayFile != "" {
+		n.sendReplay(ctx)
+		return
+	}
+	if sendQueueSize > 0 {
+		defer n.startSendQueue(ctx, sendQueueSize)()
+	}
+	var tick <-chan time.Time
+	if sendRate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / sendRate))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for i := 0; messageCount < 0 || i < messageCount; i++ {
+		if processing.WaitCtx(ctx) != nil {
+			return
+		}
+		message := fmt.Sprintf("message %d from node %s.", i, n.Name)
+		// With `-size`, the node sends generated messages of that size instead (see `payload.go`).
+		if payloadSize > 0 {
+			message = string(makePayload(payloadSize))
+		}
+		var err error
+		if sendQueueSize > 0 {
+			err = n.Enqueue(message)
+		} else {
+			err = n.sendOne(ctx, message)
+		}
+		if err == ErrQueueFull {
+			logError("Node %s: Send queue is full, dropping message %d\n", n.Name, i)
+			err = nil
+		}
+		if err == context.Canceled {
+			return
+		}
+		if err != nil {
+			log.Fatalf("Node %s failed to send '%s': %s\n", n.Name, message, err.Error())
+		}
+		if tick == nil {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick:
+		}
+	}
+}
 
-	// Now the two processes should have found their role as the listening or the dialing part. The rest is just a simple loop that sends a message and then waits for a reply. It then sleeps for one second, for a more dramatic effect in your terminal, and repeats.
-	for i := 0; i < 3; i++ {
-		send(socket, fmt.Sprintf("message %d from node %s.", i, node))
-		_ = receive(socket)
-		time.Sleep(1 * time.Second)
+// sendOne sends a single message, and with `-reliable`, waits for the acknowledgement (see `reliable.go`).
+func (n *Node) sendOne(ctx context.Context, message string) error {
+	if reliable {
+		return n.sendReliable(ctx, n.newMessage(message))
 	}
-	log.Printf("Node %s: Done.\n", node)
+	return n.sendCtx(ctx, message)
 }
 
-// Finally, our main() function only needs to fetch the arguments, store the node number, and run the node code.
+// The receiver receives messages until the socket gets closed, or until no message has arrived for the duration of the receive deadline. As the peer sends at its own pace, the receive deadline is the only way to find out that the peer is done.
+//
+// On an interrupt, the receiver drains the messages that have already arrived before it stops (see `drain.go`).
+//
+// Each message goes to the handler that the application has registered with `OnMessage()` (see `handler.go`).
+func (n *Node) receiveLoop(ctx context.Context) {
+	defer n.startHandlers()()
+	for {
+		// If the node gets interrupted while it is paused, receiveCtx reports the cancellation right away, and the loop drains the socket.
+		processing.WaitCtx(ctx)
+		m, err := n.receiveCtx(ctx)
+		if err == context.Canceled {
+			if drained := n.drain(drainTimeout, n.dispatch); drained > 0 {
+				logInfo("Node %s: Drained %d messages.\n", n.Name, drained)
+			}
+			return
+		}
+		if errors.Is(err, mangos.ErrClosed) {
+			return
+		}
+		if err == nil {
+			n.dispatch(m)
+		}
+		if errors.Is(err, ErrTimeout) {
+			logInfo("Node %s: No more messages.\n", n.Name)
+			return
+		}
+		if err != nil {
+			logError("Node %s failed receiving a message: %s\n", n.Name, err.Error())
+			return
+		}
+	}
+}
+
+// Finally, our main() function only needs to parse the options, fetch the arguments, create the node, and run the node code.
 func main() {
-	if len(os.Args) <= 2 {
-		log.Printf("Usage: %s 0|1 <url>\n", os.Args[0])
-	} else {
-		node = os.Args[1]
-		runNode(os.Args[2])
+	var o options
+	defineFlags(&o)
+	cmd, args := parseCommand()
+	if o.jsonLogs {
+		logger = newJSONLogger(os.Stderr)
+	}
+	if !o.selftest && (cmd == nil || len(args) < cmd.minArgs) {
+		if cmd == nil {
+			flag.Usage()
+		} else {
+			cmd.fs.Usage()
+		}
+		return
+	}
+	if forceListen && forceDial {
+		log.Fatalf("The -listen and -dial options exclude each other\n")
+	}
+	if o.verbose && o.quiet {
+		log.Fatalf("The -v and -q options exclude each other\n")
+	}
+	if o.verbose {
+		level = levelDebug
+	}
+	if o.quiet {
+		level = levelError
+	}
+	if ttl < 0 || ttl > 255 {
+		log.Fatalf("Invalid TTL %d: must be between 1 and 255, or 0 for the default\n", ttl)
+	}
+	if readStdin && replayFile != "" {
+		log.Fatalf("The -stdin and -replay options exclude each other\n")
+	}
+	if ackRetries < 0 {
+		log.Fatalf("Invalid number of ACK retries %d: must not be negative\n", ackRetries)
+	}
+	if sendRate < 0 {
+		log.Fatalf("Invalid rate %g: must not be negative\n", sendRate)
+	}
+	if dialAttempts < 1 {
+		log.Fatalf("Invalid number of dial attempts %d: must be at least 1\n", dialAttempts)
+	}
+	if benchN < 1 {
+		log.Fatalf("Invalid number of bench messages %d: must be at least 1\n", benchN)
+	}
+	if payloadSize < 0 {
+		log.Fatalf("Invalid message size %d: must not be negative\n", payloadSize)
+	}
+	if logSample < 0 || logSample > 1 {
+		log.Fatalf("Invalid log sample rate %g: must be between 0 and 1\n", logSample)
 	}
+	switch duplexMode {
+	case "duplex", "pingpong", "fire-forget", "receive-only":
+	default:
+		log.Fatalf("Invalid duplex mode '%s': must be duplex, pingpong, fire-forget, or receive-only\n", duplexMode)
+	}
+	c, ok := codecs[o.codecName]
+	if !ok {
+		log.Fatalf("Invalid codec '%s': must be json, gob, or protobuf\n", o.codecName)
+	}
+	msgCodec = c
+	if o.filter != "" {
+		messageFilter = parseFilter(o.filter)
+	}
+	if o.perm != "" {
+		mode, err := strconv.ParseUint(o.perm, 8, 32)
+		if err != nil || mode > 0777 {
+			log.Fatalf("Invalid ipc file mode '%s': must be an octal number up to 0777\n", o.perm)
+		}
+		ipcPerm = os.FileMode(mode)
+	}
+	var err error
+	if o.key != "" {
+		payloadCipher, err = newPayloadCipher(o.key)
+		if err != nil {
+			log.Fatalf("Invalid encryption key: %s\n", err.Error())
+		}
+	}
+	if o.certFile != "" || o.keyFile != "" || o.caFile != "" {
+		tlsConfig, err = newTLSConfig(o.certFile, o.keyFile, o.caFile)
+		if err != nil {
+			log.Fatalf("Invalid TLS options: %s\n", err.Error())
+		}
+	}
+	// Compression must come before encryption, as encrypted data looks random and does not compress.
+	if compressPayloads {
+		UseSend(compress)
+		UseRecv(decompress)
+	}
+	if payloadCipher != nil {
+		UseSend(encrypt)
+		UseRecv(decrypt)
+	}
+	allowed, err = parseAllowList(o.allow)
+	if err != nil {
+		log.Fatalf("Invalid allow list '%s': %s\n", o.allow, err.Error())
+	}
+	if o.selftest {
+		if err := selfTest(o.recvTimeout); err != nil {
+			log.Fatalf("Self-test failed: %s\n", err.Error())
+		}
+		logInfo("Self-test passed.\n")
+		return
+	}
+	// Most nodes are named after their command, but the PAIR nodes get their names from the command line.
+	n := newNode(cmd.name)
+	if cmd.namedByArg {
+		n.Name, args = args[0], args[1:]
+	}
+	if o.validate {
+		if !validateURLs(commandURLs(cmd.name, args)) {
+			os.Exit(1)
+		}
+		return
+	}
+	if o.waitURL != "" {
+		if err := waitFor(o.waitURL, o.waitTimeout); err != nil {
+			log.Fatalf("Node %s: Dependency unavailable: %s\n", n.Name, err.Error())
+		}
+	}
+	if o.dry {
+		url := ""
+		if len(args) > 0 {
+			url = args[0]
+		}
+		if err := n.dryRun(url, o.recvTimeout); err != nil {
+			log.Fatalf("Node %s: Dry run failed: %s\n", n.Name, err.Error())
+		}
+		return
+	}
+	if o.capture != "" {
+		captured, err = openCapture(o.capture)
+		if err != nil {
+			log.Fatalf("Node %s: Cannot open the capture file: %s\n", n.Name, err.Error())
+		}
+		defer func() {
+			if err := captured.Close(); err != nil {
+				logError("Node %s: %s\n", n.Name, err.Error())
+			}
+		}()
+		if !cmd.handlesInterrupt {
+			captured.closeOnInterrupt()
+		}
+	}
+	if o.metricsAddr != "" {
+		serveMetrics(o.metricsAddr, n.Name)
+	}
+	// The health server stops when the node is done.
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	if o.healthAddr != "" {
+		n.serveHealth(ctx, o.healthAddr)
+	}
+	handlePauseSignals()
+	// Besides the two PAIR nodes, the program can also run as a publisher or subscriber (see `pubsub.go`), as a requester or replier (see `reqrep.go`), as a pipeline stage (see `pipeline.go`), as a surveyor or respondent (see `survey.go`), or as a bus node (see `bus.go`). The `bench` command measures the throughput and latency of a protocol and transport (see `bench.go`), the `pool` command sends to several PAIR nodes in turn (see `pairpool.go`), and the `wsmux` command serves several sockets on one WebSocket port (see `wsmux.go`). Each of them is a subcommand (see `commands.go`).
+	cmd.run(n, args, o.recvTimeout)
 }
 
 /*
@@ -299,10 +775,15 @@ and in the other one, type
 
 (Note that you can pick an arbitrary port number from the "Dynamic" range between 49,151 and 65,535 - they only need to be the same for both processes.)
 
+By default, the nodes only tell what happens to them. To see every message that they send and receive, add the `-v` option:
+
+	$ ./messaging -v 0 "tcp://localhost:54545"
+	$ ./messaging -v 1 "tcp://localhost:54545"
+
 If you started node 0 first, your output should look like this:
 
 ```
-$ ./messaging 0 "tcp://localhost:45454"
+$ ./messaging -v 0 "tcp://localhost:45454"
 2016/02/04 11:44:55 Node 0 sends message 0 from node 0.
 2016/02/04 11:44:58 Node 0 received message 0 from node 1.
 2016/02/04 11:44:58 Node 0 sends message 1 from node 0.
@@ -315,7 +796,7 @@ $ ./messaging 0 "tcp://localhost:45454"
 And node 1 should have procuded something like this:
 
 ```
-$ ./messaging 1 "tcp://localhost:45454"
+$ ./messaging -v 1 "tcp://localhost:45454"
 2016/02/04 11:44:58 Node 1 cannot listen on socket 'tcp://localhost:45454': listen tcp 127.0.0.1:45454: bind: address already in use
 Trying to dial instead
 2016/02/04 11:44:58 Node 1 sends message 0 from node 1.
@@ -331,11 +812,18 @@ Trying to dial instead
 
 Try ipc: instead of tcp:
 
+Or try WebSockets. A ws: URL needs a path in addition to host and port:
+
+	$ ./messaging 0 "ws://localhost:54545/pair"
+	$ ./messaging 1 "ws://localhost:54545/pair"
+
 ## Exercise 2
 
-The loop in `runNode` may seem silly as it serializes sending and receiving for no good reason (other than trying to remain simple).
+The ping-pong loop (now in `pingPong()`, see `-mode-duplex=pingpong`) may seem silly as it serializes sending and receiving for no good reason (other than trying to remain simple).
 Turn the loop into two goroutines that send and receive independently.
 
+(Solution: see `duplex()`, which is what the nodes now do by default.)
+
 
 What's next?
 ------------
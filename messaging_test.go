@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-mangos/mangos"
+)
+
+// The listener goes away and comes back on the same URL. The dialer does nothing but keep sending, and Mangos brings the connection back.
+//
+// This test needs TCP on the loopback interface: an inproc pipe never tells the dialer that the listener has closed it, so there would be nothing to reconnect.
+func TestDialerReconnectsAfterListenerRestart(t *testing.T) {
+	defer func(d time.Duration) { reconnectTime = d }(reconnectTime)
+	reconnectTime = 10 * time.Millisecond
+
+	url := "tcp://" + freeAddr(t)
+	l, d := newTestNode(t, "listener"), newTestNode(t, "dialer")
+	defer closeNodes(l, d)
+	listenAndDial(t, l, d, url)
+	if err := d.Send("before"); err != nil {
+		t.Fatal(err)
+	}
+	expectBody(t, l, "before")
+
+	l.socket.Close()
+	restarted := newTestNode(t, "restarted")
+	defer closeNodes(restarted)
+	connected := onConnect(restarted.socket)
+	if err := listen(restarted.socket, url); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-connected:
+	case <-time.After(testTimeout):
+		t.Fatal("the dialer did not reconnect")
+	}
+	if err := d.Send("after"); err != nil {
+		t.Fatal(err)
+	}
+	expectBody(t, restarted, "after")
+}
+
+// A peer that sends more than -max-msg-size bytes does not get its message through. Like the reconnect test, this one runs over TCP, because only the stream transports enforce the limit.
+func TestMaxMsgSizeRejectsLargeMessage(t *testing.T) {
+	defer func(size int) { maxMsgSize = size }(maxMsgSize)
+	maxMsgSize = 1024
+
+	l, d := newTestNode(t, "listener"), newTestNode(t, "dialer")
+	defer closeNodes(l, d)
+	listenAndDial(t, l, d, "tcp://"+freeAddr(t))
+	l.socket.SetOption(mangos.OptionRecvDeadline, 200*time.Millisecond)
+	if err := d.Send(strings.Repeat("x", 4*maxMsgSize)); err != nil {
+		t.Fatal(err)
+	}
+	m, err := l.Receive()
+	if err == nil {
+		t.Fatalf("received a message of %d bytes despite a limit of %d bytes", len(m.Body), maxMsgSize)
+	}
+}
+
+// The listener never reads. Once its receive queue and the dialer's send queue are full, Send must give up after -send-timeout instead of blocking forever.
+func TestSendTimeoutOnFullBuffer(t *testing.T) {
+	defer func(d, l time.Duration, q int) { sendTimeout, linger, writeQLen = d, l, q }(sendTimeout, linger, writeQLen)
+	// Without linger, closing the sockets at the end does not wait for the queued messages.
+	sendTimeout, linger, writeQLen = 50*time.Millisecond, 0, 1
+
+	l, d := newTestPair(t)
+	defer closeNodes(l, d)
+	for i := 0; i < 10000; i++ {
+		err := d.Send("filler")
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, ErrTimeout) {
+			t.Fatalf("got %v, want an error of class ErrTimeout", err)
+		}
+		return
+	}
+	t.Fatal("the send buffer never filled up")
+}
+
+// A node that closes its socket right after sending must not lose the message that is still in the send queue: closing waits up to -linger for the queue to empty.
+func TestLingerDeliversAfterClose(t *testing.T) {
+	defer func(d time.Duration) { linger = d }(linger)
+	linger = time.Second
+
+	l, d := newTestPair(t)
+	defer closeNodes(l, d)
+	for i := 0; i < 10; i++ {
+		if err := d.Send(fmt.Sprintf("last words %d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	d.socket.Close()
+	for i := 0; i < 10; i++ {
+		expectBody(t, l, fmt.Sprintf("last words %d", i))
+	}
+}
+
+// Closing the socket is the normal way to stop a node. A receiver that is blocked in Recv() at that moment must get mangos.ErrClosed and return, rather than end the process with a fatal error.
+func TestReceiverExitsOnClose(t *testing.T) {
+	l, d := newTestPair(t)
+	defer closeNodes(l, d)
+	l.socket.SetOption(mangos.OptionRecvDeadline, time.Duration(0))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		l.receiveLoop(context.Background())
+	}()
+	// Give the receiver the time to block in Recv().
+	time.Sleep(50 * time.Millisecond)
+	l.socket.Close()
+	select {
+	case <-done:
+	case <-time.After(testTimeout):
+		t.Fatal("the receive loop did not return after the socket was closed")
+	}
+	if _, err := l.Receive(); !errors.Is(err, mangos.ErrClosed) {
+		t.Errorf("Receive() on a closed socket: got %v, want mangos.ErrClosed", err)
+	}
+}
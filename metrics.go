@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/go-mangos/mangos"
+)
+
+// metrics counts what a node does. The sending and the receiving goroutines update the counters at the same time, so all access goes through `sync/atomic`, which needs no locks.
+type metrics struct {
+	sent         uint64
+	received     uint64
+	sendErrors   uint64
+	recvTimeouts uint64
+	// queued is the number of messages in the send queue. Unlike the other fields, it is a gauge that also goes down.
+	queued int64
+	// node is the name that labels the counters.
+	node string
+}
+
+// stats holds the metrics of this node.
+var stats metrics
+
+// countSend counts a successful send, or a failed one if err is not nil.
+func (m *metrics) countSend(err error) {
+	if err != nil {
+		atomic.AddUint64(&m.sendErrors, 1)
+		return
+	}
+	atomic.AddUint64(&m.sent, 1)
+}
+
+// countRecv counts a received message, or a receive timeout. Other receive errors are not counted.
+func (m *metrics) countRecv(err error) {
+	switch err {
+	case nil:
+		atomic.AddUint64(&m.received, 1)
+	case mangos.ErrRecvTimeout:
+		atomic.AddUint64(&m.recvTimeouts, 1)
+	}
+}
+
+// ServeHTTP writes the counters in the Prometheus text format, so that Prometheus can scrape a running node.
+func (m *metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	counters := []struct {
+		name, help string
+		value      *uint64
+	}{
+		{"messaging_messages_sent_total", "Number of messages sent.", &m.sent},
+		{"messaging_messages_received_total", "Number of messages received.", &m.received},
+		{"messaging_send_errors_total", "Number of messages that could not be sent.", &m.sendErrors},
+		{"messaging_receive_timeouts_total", "Number of receive attempts that timed out.", &m.recvTimeouts},
+		{"messaging_messages_dropped_total", "Number of received messages that the filter dropped.", &dropped},
+	}
+	for _, c := range counters {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n%s{node=%q} %d\n", c.name, c.help, c.name, c.name, m.node, atomic.LoadUint64(c.value))
+	}
+	fmt.Fprintf(w, "# HELP messaging_send_queue_depth Number of messages waiting in the send queue.\n# TYPE messaging_send_queue_depth gauge\nmessaging_send_queue_depth{node=%q} %d\n", m.node, atomic.LoadInt64(&m.queued))
+}
+
+// serveMetrics serves the metrics at `/metrics` on addr in the background. A node that cannot serve its metrics keeps working, so a failure only gets logged.
+func serveMetrics(addr, node string) {
+	stats.node = node
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", &stats)
+	go func() {
+		err := http.ListenAndServe(addr, mux)
+		logError("Cannot serve metrics on '%s': %s\n", addr, err.Error())
+	}()
+}
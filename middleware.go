@@ -0,0 +1,77 @@
+package main
+
+// Compression and encryption both take the encoded message as bytes and turn it into other bytes, and the receiver undoes them in reverse order. Rather than hardcoding each of them in `pack()` and `unpack()`, we keep two chains of such transformations: one that runs on every outgoing payload, and one that runs on every incoming payload. Anything that fits the pattern, like a checksum or an extra envelope, can join the chains without touching `pack()` and `unpack()`.
+//
+// The codec (JSON, gob, or protobuf) is the first middleware of each chain: on sending, it wraps the message body in the `Message` envelope and encodes the envelope; on receiving, it decodes the envelope and hands on the body. Unlike the other middlewares, the codec needs the envelope of the message at hand, so `pack()` and `unpack()` create it for each message (see `encodeEnvelope()` and `decodeEnvelope()`) rather than registering it once.
+
+// A Middleware transforms a payload. It returns an error if the payload cannot be transformed, for example because it was corrupted on the way.
+type Middleware func([]byte) ([]byte, error)
+
+// sendChain and recvChain hold the middlewares in the order of their registration.
+var (
+	sendChain []Middleware
+	recvChain []Middleware
+)
+
+// UseSend appends m to the middlewares that run on sending. They run in the order of registration.
+func UseSend(m Middleware) {
+	sendChain = append(sendChain, m)
+}
+
+// UseRecv appends m to the middlewares that run on receiving. They run in the reverse order of registration, so that registering each send middleware together with its counterpart, like
+//
+//	UseSend(compress)
+//	UseRecv(decompress)
+//	UseSend(encrypt)
+//	UseRecv(decrypt)
+//
+// undoes the transformations in the right order: the receiver decrypts first, then decompresses. Register all middlewares before the first message gets sent or received, as the chains are not safe for concurrent use.
+func UseRecv(m Middleware) {
+	recvChain = append(recvChain, m)
+}
+
+// encodeEnvelope returns the codec middleware for sending m: it puts the payload into m's body and encodes m with c.
+func encodeEnvelope(c codec, m Message) Middleware {
+	return func(body []byte) ([]byte, error) {
+		m.Body = string(body)
+		return c.encode(m)
+	}
+}
+
+// decodeEnvelope returns the codec middleware for receiving: it decodes the payload with c into m, and returns m's body.
+func decodeEnvelope(c codec, m *Message) Middleware {
+	return func(payload []byte) ([]byte, error) {
+		var err error
+		*m, err = c.decode(payload)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(m.Body), nil
+	}
+}
+
+// applySend runs the codec and then the send chain on payload.
+func applySend(payload []byte, codec Middleware) ([]byte, error) {
+	return runChain(payload, append([]Middleware{codec}, sendChain...))
+}
+
+// applyRecv runs the receive chain on payload, last middleware first, and the codec at the very end.
+func applyRecv(payload []byte, codec Middleware) ([]byte, error) {
+	chain := make([]Middleware, 0, len(recvChain)+1)
+	for i := len(recvChain) - 1; i >= 0; i-- {
+		chain = append(chain, recvChain[i])
+	}
+	return runChain(payload, append(chain, codec))
+}
+
+// runChain runs the middlewares on payload, one after the other, and stops at the first error.
+func runChain(payload []byte, chain []Middleware) ([]byte, error) {
+	var err error
+	for _, m := range chain {
+		payload, err = m(payload)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return payload, nil
+}
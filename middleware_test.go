@@ -0,0 +1,75 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// useChains registers compression and encryption, like main does for `-compress` and `-encrypt-key`. It returns a function that restores the chains and the cipher, for the test to defer.
+func useChains(t *testing.T) (restore func()) {
+	t.Helper()
+	c, err := newPayloadCipher(strings.Repeat("ab", keySize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	savedSend, savedRecv, savedCipher := sendChain, recvChain, payloadCipher
+	payloadCipher = c
+	UseSend(compress)
+	UseRecv(decompress)
+	UseSend(encrypt)
+	UseRecv(decrypt)
+	return func() {
+		sendChain, recvChain, payloadCipher = savedSend, savedRecv, savedCipher
+	}
+}
+
+// With every codec as the first middleware, and compression and encryption after it, a message must survive pack and unpack.
+func TestPackUnpackThroughChain(t *testing.T) {
+	defer func(c codec) { msgCodec = c }(msgCodec)
+	defer useChains(t)()
+	for name, c := range codecs {
+		msgCodec = c
+		payload, err := pack(testMessage)
+		if err != nil {
+			t.Fatalf("%s: cannot pack: %s", name, err)
+		}
+		m, err := unpack(payload)
+		if err != nil {
+			t.Fatalf("%s: cannot unpack: %s", name, err)
+		}
+		if !m.SentAt.Equal(testMessage.SentAt) {
+			t.Errorf("%s: SentAt: got %v, want %v", name, m.SentAt, testMessage.SentAt)
+		}
+		m.SentAt = testMessage.SentAt
+		if m != testMessage {
+			t.Errorf("%s: got %+v, want %+v", name, m, testMessage)
+		}
+	}
+}
+
+// The codec runs first on sending and last on receiving, and the receive chain runs in reverse order of registration.
+func TestChainOrder(t *testing.T) {
+	var order []string
+	mark := func(name string) Middleware {
+		return func(b []byte) ([]byte, error) {
+			order = append(order, name)
+			return b, nil
+		}
+	}
+	defer func(s, r []Middleware) { sendChain, recvChain = s, r }(sendChain, recvChain)
+	UseSend(mark("send1"))
+	UseRecv(mark("recv1"))
+	UseSend(mark("send2"))
+	UseRecv(mark("recv2"))
+
+	if _, err := applySend([]byte("body"), mark("encode")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := applyRecv([]byte("payload"), mark("decode")); err != nil {
+		t.Fatal(err)
+	}
+	want := "encode send1 send2 recv2 recv1 decode"
+	if got := strings.Join(order, " "); got != want {
+		t.Errorf("got order %s, want %s", got, want)
+	}
+}
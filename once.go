@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// With `-once`, a PAIR node sends a single message and exits, which makes it easy to use in shell scripts:
+//
+//	$ ./messaging -once -message "backup done" 1 tcp://monitor:54545
+//	$ date | ./messaging -once -reply 1 tcp://timeserver:54545
+//
+// Without `-message`, the message is everything that standard input delivers. With `-reply`, the node also waits for one reply and prints its body to standard output. If the node cannot send the message, or no reply arrives within the receive deadline, it exits with a non-zero status, so that the script can tell.
+var (
+	sendOnce    bool
+	onceMessage string
+	onceReply   bool
+)
+
+// once sends the message, and with onceReply, waits for the reply. It waits up to timeout for a peer to connect, or forever if timeout is zero.
+func (n *Node) once(ctx context.Context, timeout time.Duration) error {
+	message := onceMessage
+	if message == "" {
+		input, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("cannot read standard input: %w", err)
+		}
+		message = strings.TrimSuffix(string(input), "\n")
+	}
+	if err := n.waitForPeer(ctx, timeout); err != nil {
+		return err
+	}
+	if err := n.sendOne(ctx, message); err != nil {
+		return err
+	}
+	if !onceReply {
+		return nil
+	}
+	reply, err := n.receiveCtx(ctx)
+	if err != nil {
+		return fmt.Errorf("no reply: %w", err)
+	}
+	fmt.Println(reply.Body)
+	return nil
+}
+
+// waitForPeer waits until the node has a connection. A PAIR socket happily takes a message without any peer, and keeps it until one connects, so a successful send alone does not mean much. Hence once waits for the connection first, and fails with ErrNoConnection if no peer connects in time.
+func (n *Node) waitForPeer(ctx context.Context, timeout time.Duration) error {
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		deadline = time.After(timeout)
+	}
+	tick := time.NewTicker(10 * time.Millisecond)
+	defer tick.Stop()
+	for atomic.LoadInt32(&n.connections) == 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-deadline:
+			return &Error{Class: ErrNoConnection, Err: fmt.Errorf("no peer connected within %s", timeout)}
+		case <-tick.C:
+		}
+	}
+	return nil
+}
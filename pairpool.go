@@ -0,0 +1,134 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-mangos/mangos"
+)
+
+// A PAIR socket talks to exactly one peer. To send messages to several peers, a node needs several PAIR sockets, one per peer. A PairPool bundles such sockets and spreads the outgoing messages across them in turn, which roughly imitates a PUSH socket. Roughly, because a PairPool can only skip a peer that is gone, whereas a PUSH socket also avoids peers that are slow, and it never loses a message that sits in the buffer of a peer that goes away. To see the difference, run the `pool` command with a few PAIR nodes, and stop one of them while the pool sends.
+
+// poolSendTimeout is how long a PairPool waits for room in a socket's send buffer before it tries the next socket.
+var poolSendTimeout = 100 * time.Millisecond
+
+// poolPeerTimeout is how long runPool waits for the first peer to connect.
+var poolPeerTimeout = 10 * time.Second
+
+// poolMember is one socket of a PairPool, together with the number of its connections.
+type poolMember struct {
+	url    string
+	socket mangos.Socket
+	peers  int32
+}
+
+// PairPool sends messages round-robin over several PAIR sockets.
+type PairPool struct {
+	mu      sync.Mutex
+	members []*poolMember
+	next    int
+}
+
+// NewPairPool creates a PAIR socket for each URL and listens on it. Each peer dials one of the URLs.
+//
+// The sockets belong to n and get the same options as n's other sockets, like `-allow`, `-max-peers`, or the TLS configuration (see `setupSocket()`). Only the send deadline is the pool's own, so that a slow peer cannot hold up the others.
+func (n *Node) NewPairPool(urls []string) (*PairPool, error) {
+	p := &PairPool{}
+	for _, url := range urls {
+		socket, err := n.newSocket(0)
+		if err != nil {
+			p.Close()
+			return nil, err
+		}
+		m := &poolMember{url: url, socket: socket}
+		socket.SetOption(mangos.OptionSendDeadline, poolSendTimeout)
+		// Count the peers after the hooks of setupSocket() have had their say.
+		hook := socket.SetPortHook(nil)
+		socket.SetPortHook(portHooks(hook, func(action mangos.PortAction, port mangos.Port) bool {
+			switch action {
+			case mangos.PortActionAdd:
+				atomic.AddInt32(&m.peers, 1)
+			case mangos.PortActionRemove:
+				atomic.AddInt32(&m.peers, -1)
+			}
+			return true
+		}))
+		p.members = append(p.members, m)
+		if err = listen(socket, url); err != nil {
+			p.Close()
+			return nil, fmt.Errorf("cannot listen on '%s': %w", url, err)
+		}
+	}
+	return p, nil
+}
+
+// errNoPeer tells that none of the sockets of a PairPool could send a message.
+var errNoPeer = &Error{Class: ErrNoConnection, Err: errors.New("no connected peer accepted the message")}
+
+// Send sends payload over the next socket that has a peer. A socket without a peer, or one whose send fails, is skipped, and the next one gets its turn. Send only fails if all sockets have been tried.
+func (p *PairPool) Send(payload []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for range p.members {
+		m := p.members[p.next]
+		p.next = (p.next + 1) % len(p.members)
+		if atomic.LoadInt32(&m.peers) == 0 {
+			continue
+		}
+		err := m.socket.Send(payload)
+		if err == nil {
+			return nil
+		}
+		logError("Pool: cannot send over '%s', trying the next peer: %s\n", m.url, err.Error())
+	}
+	return errNoPeer
+}
+
+// Close closes all sockets of the pool.
+func (p *PairPool) Close() {
+	for _, m := range p.members {
+		m.socket.Close()
+	}
+}
+
+// runPool listens on the URLs and sends the messages to the PAIR nodes that dial them, one node after the other. It waits up to poolPeerTimeout for the first peer before it starts.
+func (n *Node) runPool(urls []string) {
+	pool, err := n.NewPairPool(urls)
+	if err != nil {
+		log.Fatalf("Node %s: Cannot create the pool: %s\n", n.Name, err.Error())
+	}
+	defer pool.Close()
+	for i := 0; messageCount < 0 || i < messageCount; i++ {
+		processing.Wait()
+		m := n.newMessage(fmt.Sprintf("message %d from node %s.", i, n.Name))
+		payload, err := pack(m)
+		if err != nil {
+			log.Fatalf("Node %s: %s\n", n.Name, err.Error())
+		}
+		err = pool.Send(payload)
+		if i == 0 {
+			deadline := time.Now().Add(poolPeerTimeout)
+			for err == errNoPeer && time.Now().Before(deadline) {
+				time.Sleep(100 * time.Millisecond)
+				err = pool.Send(payload)
+			}
+			if err == errNoPeer {
+				log.Fatalf("Node %s: No peer connected within %s\n", n.Name, poolPeerTimeout)
+			}
+		}
+		stats.countSend(err)
+		if err != nil {
+			logError("Node %s failed to send message %d: %s\n", n.Name, m.Seq, err.Error())
+		} else {
+			logMessage(Event{Node: n.Name, Event: "send", Seq: m.Seq, Body: m.Body}, "Node %s sends %s\n", n.Name, m.Body)
+		}
+		if sendRate > 0 {
+			time.Sleep(time.Duration(float64(time.Second) / sendRate))
+		}
+	}
+	logInfo("Node %s: Done.\n", n.Name)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-mangos/mangos"
+)
+
+func TestPairPoolRoundRobin(t *testing.T) {
+	defer func(size int) { maxMsgSize = size }(maxMsgSize)
+	maxMsgSize = 4096
+
+	urls := []string{testURL(t, "-a"), testURL(t, "-b")}
+	pool, err := newNode("pool").NewPairPool(urls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	// The pooled sockets get their options from setupSocket(), like all other sockets.
+	for _, m := range pool.members {
+		if size, err := m.socket.GetOption(mangos.OptionMaxRecvSize); err != nil || size != maxMsgSize {
+			t.Errorf("socket for '%s' has a maximum message size of %v, want %d", m.url, size, maxMsgSize)
+		}
+	}
+
+	var peers []*Node
+	for _, url := range urls {
+		peer := newTestNode(t, "peer")
+		defer closeNodes(peer)
+		if err := dial(peer.socket, url); err != nil {
+			t.Fatal(err)
+		}
+		peers = append(peers, peer)
+	}
+	deadline := time.Now().Add(testTimeout)
+	for _, m := range pool.members {
+		for atomic.LoadInt32(&m.peers) == 0 && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	for i := 0; i < 4; i++ {
+		payload, err := pack(Message{From: "pool", Seq: i, Body: fmt.Sprint(i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := pool.Send(payload); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Peer 0 gets the messages 0 and 2, peer 1 gets 1 and 3.
+	for i := 0; i < 4; i++ {
+		expectBody(t, peers[i%2], fmt.Sprint(i))
+	}
+}
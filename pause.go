@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// pauser lets an operator temporarily stop a node from sending and receiving without closing its connections.
+type pauser struct {
+	mu     sync.Mutex
+	resume chan struct{} // nil while running; closed on resume
+}
+
+// processing controls the message loop of this node.
+var processing pauser
+
+// Pause makes subsequent calls to Wait block until Resume is called.
+func (p *pauser) Pause() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.resume == nil {
+		p.resume = make(chan struct{})
+		logInfo("Paused.\n")
+	}
+}
+
+// Resume releases all goroutines blocked in Wait.
+func (p *pauser) Resume() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.resume != nil {
+		close(p.resume)
+		p.resume = nil
+		logInfo("Resumed.\n")
+	}
+}
+
+// Wait blocks while processing is paused. While a node waits here, it neither sends nor reads from its socket, so incoming messages queue up in the socket's buffers until the peer finally feels the back-pressure.
+func (p *pauser) Wait() {
+	p.mu.Lock()
+	resume := p.resume
+	p.mu.Unlock()
+	if resume != nil {
+		<-resume
+	}
+}
+
+// WaitCtx works like Wait but returns ctx.Err() as soon as the context is cancelled, so that Ctrl-C still stops a paused node.
+func (p *pauser) WaitCtx(ctx context.Context) error {
+	p.mu.Lock()
+	resume := p.resume
+	p.mu.Unlock()
+	if resume == nil {
+		return ctx.Err()
+	}
+	select {
+	case <-resume:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWaitCtxReturnsOnCancelWhilePaused(t *testing.T) {
+	var p pauser
+	p.Pause()
+	defer p.Resume()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- p.WaitCtx(ctx) }()
+	select {
+	case <-done:
+		t.Fatal("WaitCtx returned while paused")
+	case <-time.After(50 * time.Millisecond):
+	}
+	cancel()
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Fatalf("got %v, want context.Canceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("WaitCtx did not return after cancel")
+	}
+}
+
+func TestWaitCtxReturnsOnResume(t *testing.T) {
+	var p pauser
+	p.Pause()
+	done := make(chan error, 1)
+	go func() { done <- p.WaitCtx(context.Background()) }()
+	p.Resume()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("got %v, want nil", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("WaitCtx did not return after resume")
+	}
+}
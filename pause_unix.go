@@ -0,0 +1,25 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// handlePauseSignals pauses the node on SIGUSR1 and resumes it on SIGUSR2.
+func handlePauseSignals() {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGUSR1, syscall.SIGUSR2)
+	go func() {
+		for s := range sig {
+			if s == syscall.SIGUSR1 {
+				processing.Pause()
+			} else {
+				processing.Resume()
+			}
+		}
+	}()
+}
@@ -0,0 +1,28 @@
+package main
+
+import "fmt"
+
+// payloadSize is the size in bytes of the generated messages that a PAIR node sends instead of the text messages. Zero means text messages. Fixed-size messages are useful for measuring throughput, and for trying out the `-max-msg-size` limit.
+var payloadSize int
+
+// makePayload returns size bytes of a fixed pattern: the letters of the alphabet, over and over again. The pattern only depends on the size, so the receiver can recreate it and compare.
+func makePayload(size int) []byte {
+	b := make([]byte, size)
+	for i := range b {
+		b[i] = 'a' + byte(i%26)
+	}
+	return b
+}
+
+// checkPayload verifies that body is an intact payload of size bytes from makePayload. A truncated payload still has the right pattern, so checkPayload compares the length first. Otherwise, it returns an error that points to the first byte that differs.
+func checkPayload(body string, size int) error {
+	if len(body) != size {
+		return fmt.Errorf("payload has %d bytes, want %d", len(body), size)
+	}
+	for i := 0; i < len(body); i++ {
+		if body[i] != 'a'+byte(i%26) {
+			return fmt.Errorf("payload corrupted at byte %d of %d", i, len(body))
+		}
+	}
+	return nil
+}
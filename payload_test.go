@@ -0,0 +1,21 @@
+package main
+
+import "testing"
+
+func TestCheckPayload(t *testing.T) {
+	good := string(makePayload(100))
+	if err := checkPayload(good, 100); err != nil {
+		t.Errorf("intact payload: %s", err)
+	}
+	if err := checkPayload(good[:60], 100); err == nil {
+		t.Error("truncated payload passed the check")
+	}
+	if err := checkPayload(good+"a", 100); err == nil {
+		t.Error("overlong payload passed the check")
+	}
+	corrupted := []byte(good)
+	corrupted[42] = 'X'
+	if err := checkPayload(string(corrupted), 100); err == nil {
+		t.Error("corrupted payload passed the check")
+	}
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"github.com/go-mangos/mangos"
+)
+
+// onConnect returns a channel that receives a value whenever a peer connects to the socket. Values are not queued up: if nobody reads the channel, further connects are not reported until somebody does.
+//
+// As a socket has only one port hook, onConnect chains its own hook after the one that is already installed.
+func onConnect(socket mangos.Socket) <-chan struct{} {
+	connected := make(chan struct{}, 1)
+	hook := socket.SetPortHook(nil)
+	socket.SetPortHook(portHooks(hook, func(action mangos.PortAction, port mangos.Port) bool {
+		if action == mangos.PortActionAdd {
+			select {
+			case connected <- struct{}{}:
+			default:
+			}
+		}
+		return true
+	}))
+	return connected
+}
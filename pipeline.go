@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/go-mangos/mangos"
+	"github.com/go-mangos/mangos/protocol/pull"
+	"github.com/go-mangos/mangos/protocol/push"
+)
+
+// The Pipeline example has a push node that hands out work items, and any number of pull nodes that each take their share. Unlike PubSub, every item goes to exactly one pull node; the push socket distributes the items among all connected pull nodes.
+
+// pullTimeout is how long the push node waits for the first pull node to connect.
+const pullTimeout = 30 * time.Second
+
+// newPushSocket creates a socket that speaks the PUSH protocol. A PUSH socket can only send, so it needs no receive deadline.
+func (n *Node) newPushSocket() mangos.Socket {
+	socket, err := push.NewSocket()
+	if err != nil {
+		log.Fatalf("Node %s: Cannot create socket: %s\n", n.Name, err.Error())
+	}
+	n.setupSocket(socket, 0)
+	return socket
+}
+
+// newPullSocket creates a socket that speaks the PULL protocol. A PULL socket can only receive.
+func (n *Node) newPullSocket(timeout time.Duration) mangos.Socket {
+	socket, err := pull.NewSocket()
+	if err != nil {
+		log.Fatalf("Node %s: Cannot create socket: %s\n", n.Name, err.Error())
+	}
+	n.setupSocket(socket, timeout)
+	return socket
+}
+
+// runPush listens on the URL and distributes ten work items among the pull nodes.
+//
+// While no pull node is connected, the push socket queues the items, and the queue is lost when the node exits. So before sending anything, runPush waits until the first pull node shows up.
+//
+// With `-dial`, the push node dials a listening pull node instead, so that several push nodes can feed one pull node (see `aggregate.go`). All these push nodes would be called "push", so each one adds its process ID to its name, and the pull node can tell them apart.
+func (n *Node) runPush(url string) {
+	socket := n.newPushSocket()
+	defer socket.Close()
+	n.socket = socket
+	connected := onConnect(socket)
+	if forceDial {
+		n.Name = fmt.Sprintf("%s-%d", n.Name, os.Getpid())
+		if err := dial(socket, url); err != nil {
+			log.Fatalf("Node %s cannot dial on socket '%s': %s\n", n.Name, url, err.Error())
+		}
+	} else if err := listen(socket, url); err != nil {
+		log.Fatalf("Node %s cannot listen on socket '%s': %s\n", n.Name, url, err.Error())
+	}
+	logInfo("Node %s waits for pull nodes\n", n.Name)
+	select {
+	case <-connected:
+	case <-time.After(pullTimeout):
+		log.Fatalf("Node %s: No pull node connected within %s\n", n.Name, pullTimeout)
+	}
+	for i := 0; i < 10; i++ {
+		processing.Wait()
+		n.send(fmt.Sprintf("work item %d from node %s.", i, n.Name))
+		time.Sleep(500 * time.Millisecond)
+	}
+	logInfo("Node %s: Done.\n", n.Name)
+}
+
+// runPull dials the push node's URL and prints each work item it gets, until no item has arrived for the duration of the receive deadline. At the end, it sums up what it has received.
+//
+// With `-listen`, the pull node listens instead, and collects the items of all push nodes that dial it.
+func (n *Node) runPull(url string, timeout time.Duration) {
+	socket := n.newPullSocket(timeout)
+	defer socket.Close()
+	n.socket = socket
+	if forceListen {
+		if err := listen(socket, url); err != nil {
+			log.Fatalf("Node %s cannot listen on socket '%s': %s\n", n.Name, url, err.Error())
+		}
+	} else if err := dial(socket, url); err != nil {
+		log.Fatalf("Node %s cannot dial on socket '%s': %s\n", n.Name, url, err.Error())
+	}
+	items := newAggregator()
+	for {
+		processing.Wait()
+		m, err := n.Receive()
+		if errors.Is(err, ErrTimeout) || errors.Is(err, mangos.ErrClosed) {
+			break
+		}
+		if err != nil {
+			log.Fatalf("Node %s failed receiving a work item: %s\n", n.Name, err.Error())
+		}
+		seq := items.add(m)
+		logMessage(Event{Node: n.Name, Event: "aggregate", Seq: seq, Peer: m.From}, "Node %s: Item #%d came from %s\n", n.Name, seq, m.From)
+	}
+	logInfo("Node %s: Received %s\n", n.Name, items)
+	logInfo("Node %s: Done.\n", n.Name)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"time"
+
+	"github.com/appliedgo/messaging/pb"
+	"google.golang.org/protobuf/proto"
+)
+
+// Gob is a fine encoding between Go programs, but nodes written in C++, Java, or Python cannot read it. These typically prefer protobuf, so with `-codec=protobuf`, messages travel in the protobuf wire format that `message.proto` defines.
+//
+// The Go type `pb.Message` is generated from `message.proto` with protoc-gen-go, so the definition and the Go code cannot drift apart. encodeProto and decodeProto just copy the fields between our `Message` and the generated type. Protobuf has no time type of its own in a flat message like ours, so SentAt travels as nanoseconds since the Unix epoch.
+
+// encodeProto serializes a message in the protobuf wire format.
+func encodeProto(m Message) ([]byte, error) {
+	p := &pb.Message{
+		From:      m.From,
+		Seq:       int64(m.Seq),
+		Body:      m.Body,
+		Heartbeat: m.Heartbeat,
+		Id:        m.ID,
+		Ack:       m.Ack,
+	}
+	if !m.SentAt.IsZero() {
+		p.SentAtUnixNano = m.SentAt.UnixNano()
+	}
+	return proto.Marshal(p)
+}
+
+// decodeProto deserializes a message in the protobuf wire format. As protobuf demands, it skips fields that it does not know, so that newer peers can add fields without breaking older ones.
+func decodeProto(b []byte) (Message, error) {
+	var p pb.Message
+	if err := proto.Unmarshal(b, &p); err != nil {
+		return Message{}, err
+	}
+	m := Message{
+		From:      p.From,
+		Seq:       int(p.Seq),
+		Body:      p.Body,
+		Heartbeat: p.Heartbeat,
+		ID:        p.Id,
+		Ack:       p.Ack,
+	}
+	if p.SentAtUnixNano != 0 {
+		m.SentAt = time.Unix(0, p.SentAtUnixNano)
+	}
+	return m, nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/appliedgo/messaging/pb"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestProtoRoundTrip(t *testing.T) {
+	tests := []Message{
+		{},
+		{From: "node1", Seq: 42, Body: "hello", SentAt: time.Unix(0, 1500000000123456789)},
+		{From: "node2", Seq: 7, ID: "node2-7", Ack: true},
+		{From: "node3", Heartbeat: true, SentAt: time.Now()},
+	}
+	for _, want := range tests {
+		b, err := encodeProto(want)
+		if err != nil {
+			t.Fatalf("cannot encode %+v: %s", want, err)
+		}
+		got, err := decodeProto(b)
+		if err != nil {
+			t.Fatalf("cannot decode %+v: %s", want, err)
+		}
+		if !got.SentAt.Equal(want.SentAt) {
+			t.Errorf("SentAt: got %v, want %v", got.SentAt, want.SentAt)
+		}
+		got.SentAt, want.SentAt = time.Time{}, time.Time{}
+		if got != want {
+			t.Errorf("got %+v, want %+v", got, want)
+		}
+	}
+}
+
+// A peer in another language sees only the generated type, so the bytes that encodeProto writes must make sense to pb.Message.
+func TestProtoMatchesGeneratedType(t *testing.T) {
+	b, err := encodeProto(Message{From: "node1", Seq: 3, Body: "hi", ID: "node1-3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var p pb.Message
+	if err := proto.Unmarshal(b, &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.From != "node1" || p.Seq != 3 || p.Body != "hi" || p.Id != "node1-3" {
+		t.Errorf("pb.Message got %v", &p)
+	}
+}
+
+func TestDecodeProtoRejectsGarbage(t *testing.T) {
+	if _, err := decodeProto([]byte{0x0a, 0xff}); err == nil {
+		t.Error("decodeProto accepted a truncated field")
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/go-mangos/mangos"
+	"github.com/go-mangos/mangos/protocol/pub"
+)
+
+// The PubSub example follows the structure of the PAIR example. A publisher listens on a URL and broadcasts messages on a couple of topics; any number of subscribers dial that URL and receive only the topics they have subscribed to. The subscribers manage their topics with the `Subscriber` type in `subscriber.go`.
+
+// pubTopics are the topics that the publisher sends messages on.
+var pubTopics = []string{"weather", "traffic", "news"}
+
+// topicSeparator separates the topic from the packed message. The SUB socket filters messages by comparing their first bytes to the subscribed topics, so the topic must go in front of the message, and it must not be encrypted.
+const topicSeparator = "\n"
+
+// newPubSocket creates a socket that speaks the PUB protocol. A PUB socket can only send, so it needs no receive deadline.
+func (n *Node) newPubSocket() mangos.Socket {
+	socket, err := pub.NewSocket()
+	if err != nil {
+		log.Fatalf("Node %s: Cannot create socket: %s\n", n.Name, err.Error())
+	}
+	n.setupSocket(socket, 0)
+	return socket
+}
+
+// runPub listens on the URL and publishes a few rounds of messages, one per topic and round.
+//
+// The publisher does not know about its subscribers. Messages that are sent while no subscriber is connected are lost, which is why the publisher takes a little break between the rounds.
+func (n *Node) runPub(url string) {
+	socket := n.newPubSocket()
+	defer socket.Close()
+	n.socket = socket
+	err := listen(socket, url)
+	if err != nil {
+		log.Fatalf("Node %s cannot listen on socket '%s': %s\n", n.Name, url, err.Error())
+	}
+	for i := 0; i < 10; i++ {
+		processing.Wait()
+		for _, topic := range pubTopics {
+			n.publish(topic, fmt.Sprintf("message %d from node %s.", i, n.Name))
+		}
+		time.Sleep(1 * time.Second)
+	}
+	logInfo("Node %s: Done.\n", n.Name)
+}
+
+// runSub dials the publisher's URL and prints the messages on the subscribed topics until none has arrived for the duration of the receive deadline.
+//
+// A subscription is just a prefix; the SUB socket silently discards every message that starts with none of the subscribed topics. Without any subscription, a SUB socket receives nothing at all, so if no topics are given, we subscribe to the empty prefix, which matches all messages.
+func (n *Node) runSub(url string, topics []string, timeout time.Duration) {
+	// The publisher numbers its messages across all topics, so only a subscriber that gets all topics can tell a lost message from one on another topic.
+	if len(topics) == 0 {
+		topics = []string{""}
+		n.sequences = newSequenceTracker()
+	}
+	s, err := n.newSubscriber(url, timeout)
+	if err != nil {
+		log.Fatalf("Node %s cannot dial on socket '%s': %s\n", n.Name, url, err.Error())
+	}
+	defer s.Close()
+	// The Subscriber logs each message as it receives it. All that is left to do here is to empty the channels, until the Subscriber stops and closes them.
+	var wg sync.WaitGroup
+	for _, topic := range topics {
+		ch, err := s.Subscribe(topic)
+		if err != nil {
+			log.Fatalf("Node %s: Cannot subscribe to '%s': %s\n", n.Name, topic, err.Error())
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range ch {
+				processing.Wait()
+			}
+		}()
+	}
+	wg.Wait()
+	if err := s.Err(); err != nil && !errors.Is(err, ErrTimeout) {
+		log.Fatalf("Node %s failed receiving a message: %s\n", n.Name, err.Error())
+	}
+	logInfo("Node %s: Done.\n", n.Name)
+}
+
+// publish sends a message on a topic. It works like send, except that it puts the topic in front of the packed message.
+func (n *Node) publish(topic, body string) {
+	m := n.newMessage(body)
+	logMessage(Event{Node: n.Name, Event: "publish", Seq: m.Seq, Topic: topic, Body: body}, "Node %s publishes on topic %s: %s\n", n.Name, topic, body)
+	payload, err := pack(m)
+	if err == nil {
+		err = n.socket.Send(append([]byte(topic+topicSeparator), payload...))
+	}
+	stats.countSend(err)
+	if err != nil {
+		log.Fatalf("Node %s failed to publish '%s': %s\n", n.Name, body, err.Error())
+	}
+}
+
+// receiveTopic works like Receive, except that it splits off the topic before unpacking the message.
+func (n *Node) receiveTopic() (string, Message, error) {
+	for {
+		payload, err := n.socket.Recv()
+		if err != nil {
+			stats.countRecv(err)
+			return "", Message{}, classify(err)
+		}
+		parts := bytes.SplitN(payload, []byte(topicSeparator), 2)
+		if len(parts) != 2 {
+			return "", Message{}, errors.New("message has no topic")
+		}
+		topic := string(parts[0])
+		m, err := unpack(parts[1])
+		if err != nil {
+			return "", Message{}, err
+		}
+		n.checkSequence(m)
+		if !accept([]byte(m.Body)) {
+			continue
+		}
+		logMessage(Event{Node: n.Name, Event: "receive", Seq: m.Seq, Peer: m.From, Topic: topic, Body: m.Body}, "Node %s received on topic %s: %s\n", n.Name, topic, m.Body)
+		captured.record(m, topic)
+		stats.countRecv(nil)
+		return topic, m, nil
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+
+	"github.com/go-mangos/mangos"
+)
+
+// A socket's `Send()` blocks while the send buffer is full, so a slow peer slows down the sender, too. Often this is just right, but a program that produces messages in a loop that must not stall (say, one that reads from a sensor) would rather learn that the peer cannot keep up, and decide for itself what to do about it.
+//
+// With `-send-queue`, a PAIR node therefore puts its messages into a bounded queue, and a sender goroutine of its own takes them out and sends them. `Enqueue()` never blocks: if the queue is full, it returns ErrQueueFull right away. This is backpressure that the producer can see. The current queue depth is available as a metric (see `metrics.go`).
+var sendQueueSize int
+
+// ErrQueueFull tells that the send queue has no room for another message.
+var ErrQueueFull = errors.New("send queue is full")
+
+// errNoQueue tells that Enqueue was called without a running send queue.
+var errNoQueue = errors.New("send queue is not running")
+
+// startSendQueue creates the send queue and starts the sender goroutine. Calling the returned function closes the queue and waits until the sender has sent the messages that are still in the queue, or until ctx is done. After that, Enqueue must not be called anymore.
+//
+// The queue holds just the message bodies. The sender wraps each body in a `Message` envelope right before sending it, so that a message that never made it into the queue does not use up a sequence number, and the receiver does not report it as missing.
+func (n *Node) startSendQueue(ctx context.Context, size int) (stop func()) {
+	n.queue = make(chan string, size)
+	done := make(chan struct{})
+	go n.sendQueued(ctx, done)
+	return func() {
+		close(n.queue)
+		<-done
+	}
+}
+
+// sendQueued sends the messages from the queue one by one, until the queue gets closed. A message that cannot be sent is logged and dropped; the producer has long moved on and cannot do anything about it.
+func (n *Node) sendQueued(ctx context.Context, done chan<- struct{}) {
+	defer close(done)
+	for body := range n.queue {
+		atomic.AddInt64(&stats.queued, -1)
+		if ctx.Err() != nil {
+			continue
+		}
+		m := n.newMessage(body)
+		var err error
+		if reliable {
+			err = n.sendReliable(ctx, m)
+		} else {
+			err = n.sendMessage(m)
+		}
+		if err == mangos.ErrClosed {
+			return
+		}
+		if err != nil && err != context.Canceled {
+			logError("Node %s failed to send queued message %d: %s\n", n.Name, m.Seq, err.Error())
+		}
+	}
+}
+
+// Enqueue puts a message into the send queue without waiting. It returns ErrQueueFull if the queue has no room left.
+func (n *Node) Enqueue(message string) error {
+	if n.queue == nil {
+		return errNoQueue
+	}
+	// The counter goes up first, or else the sender could take the message out and count it down before it got counted up.
+	atomic.AddInt64(&stats.queued, 1)
+	select {
+	case n.queue <- message:
+		return nil
+	default:
+		atomic.AddInt64(&stats.queued, -1)
+		return ErrQueueFull
+	}
+}
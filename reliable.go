@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// PAIR sends messages on a fire-and-forget basis: if the connection breaks while a message is under way, the message is lost, and nobody notices. With `-reliable`, nodes add a small acknowledgement protocol on top of PAIR:
+//
+// * The receiver answers each message with an ACK that carries the message's sequence number.
+// * The sender waits for the ACK before it sends the next message. If no ACK arrives within ackTimeout, it sends the message again, up to ackRetries times.
+//
+// This is at-least-once delivery: a message never gets lost unnoticed, but if the ACK is lost rather than the message, the receiver gets the message twice.
+var (
+	reliable   bool
+	ackTimeout = time.Second
+	ackRetries = 3
+)
+
+// sendReliable sends m and waits for the ACK, resending m if necessary.
+//
+// A send that runs into the send deadline counts as an attempt without ACK, as the peer may just be slow. Any other send error, like a message that cannot be encoded, does not get better by trying again.
+func (n *Node) sendReliable(ctx context.Context, m Message) error {
+	for attempt := 0; attempt <= ackRetries; attempt++ {
+		if attempt > 0 {
+			logError("Node %s got no ACK for message %d, resending\n", n.Name, m.Seq)
+		}
+		err := n.sendMessage(m)
+		if errors.Is(err, ErrTimeout) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if n.waitForAck(ctx, m.Seq) {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+	return &Error{Class: ErrTimeout, Err: fmt.Errorf("no ACK for message %d after %d attempts", m.Seq, ackRetries+1)}
+}
+
+// waitForAck waits up to ackTimeout for the ACK of message seq. Late ACKs of earlier messages are skipped.
+func (n *Node) waitForAck(ctx context.Context, seq int) bool {
+	timer := time.NewTimer(ackTimeout)
+	defer timer.Stop()
+	for {
+		select {
+		case s := <-n.acks:
+			if s == seq {
+				return true
+			}
+		case <-timer.C:
+			return false
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// acknowledge sends the ACK for m. The ACK goes out even if the filter drops m, as m did arrive.
+func (n *Node) acknowledge(m Message) {
+	payload, err := pack(Message{From: n.Name, Seq: m.Seq, SentAt: time.Now(), Ack: true})
+	if err == nil {
+		err = n.socket.Send(payload)
+	}
+	if err != nil {
+		logError("Node %s failed to acknowledge message %d: %s\n", n.Name, m.Seq, err.Error())
+	}
+}
+
+// handleAck hands an incoming ACK to the waiting sender. If no sender waits, the ACK is dropped.
+func (n *Node) handleAck(m Message) {
+	select {
+	case n.acks <- m.Seq:
+	default:
+	}
+}
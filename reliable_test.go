@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-mangos/mangos"
+)
+
+// The receiver drops the ACK of the first delivery, so the sender must send the message again, and it succeeds once the second ACK arrives.
+func TestReliableResendsWithoutAck(t *testing.T) {
+	defer func(r bool, d time.Duration) { reliable, ackTimeout = r, d }(reliable, ackTimeout)
+	reliable, ackTimeout = true, 100*time.Millisecond
+
+	l, d := newTestPair(t)
+	defer closeNodes(l, d)
+	// The sender's ACKs arrive through Receive(), so something has to receive on the sender's socket.
+	go func() {
+		for {
+			if _, err := d.Receive(); errors.Is(err, mangos.ErrClosed) {
+				return
+			}
+		}
+	}()
+	deliveries := make(chan Message, 2)
+	go func() {
+		for i := 0; i < 2; i++ {
+			payload, err := l.socket.Recv()
+			if err != nil {
+				return
+			}
+			m, err := unpack(payload)
+			if err != nil {
+				return
+			}
+			deliveries <- m
+			if i > 0 {
+				l.acknowledge(m)
+			}
+		}
+	}()
+
+	m := d.newMessage("important")
+	if err := d.sendReliable(context.Background(), m); err != nil {
+		t.Fatalf("sendReliable: %s", err)
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case got := <-deliveries:
+			if got.Seq != m.Seq || got.Body != m.Body {
+				t.Errorf("delivery %d: got message %d '%s', want %d '%s'", i+1, got.Seq, got.Body, m.Seq, m.Body)
+			}
+		case <-time.After(testTimeout):
+			t.Fatalf("only %d deliveries, want 2", i)
+		}
+	}
+}
+
+// A receiver that never acknowledges makes the sender give up after ackRetries resends, with an error of the class ErrTimeout.
+func TestReliableGivesUp(t *testing.T) {
+	defer func(r bool, d time.Duration) { reliable, ackTimeout = r, d }(reliable, ackTimeout)
+	reliable, ackTimeout = true, 20*time.Millisecond
+
+	l, d := newTestPair(t)
+	defer closeNodes(l, d)
+	err := d.sendReliable(context.Background(), d.newMessage("lost"))
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("got %v, want an error of class ErrTimeout", err)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// To reproduce a traffic pattern, a PAIR node can replay the messages from a file with `-replay`. It sends them in the order of the file, at the pace of `-rate`, and with `-loop`, it starts over at the end of the file until it gets interrupted.
+//
+// The file is either plain text, with one message per line, or JSON lines if its name ends in `.jsonl`. Each JSON line is an object with a "body" field, which is what both a `Message` and the events of `-json-logs` have, so the log of one node can serve as the traffic of another. Only the debug level logs the messages with their bodies, hence the `-v`:
+//
+//	$ ./messaging -v -json-logs 0 tcp://localhost:54545 2> traffic.jsonl
+//	$ ./messaging -replay traffic.jsonl -loop 0 tcp://localhost:54545
+//
+// A JSON line that cannot be parsed gets logged and skipped, as a single broken line should not spoil the whole replay. Empty lines are skipped silently.
+var (
+	replayFile string
+	replayLoop bool
+)
+
+// errNoBody tells that a JSON line has no "body" field, or an empty one.
+var errNoBody = errors.New(`no "body" field`)
+
+// replayBody returns the message body of a line from a replay file.
+func replayBody(line string, jsonLines bool) (string, error) {
+	if !jsonLines {
+		return line, nil
+	}
+	var m struct {
+		Body string `json:"body"`
+	}
+	if err := json.Unmarshal([]byte(line), &m); err != nil {
+		return "", err
+	}
+	if m.Body == "" {
+		return "", errNoBody
+	}
+	return m.Body, nil
+}
+
+// sendReplay sends the messages from the replay file. Like the send loop, it keeps the pace with a ticker.
+//
+// If a pass through the file sends nothing at all, `-loop` would just spin, so the replay stops then.
+func (n *Node) sendReplay(ctx context.Context) {
+	var tick <-chan time.Time
+	if sendRate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / sendRate))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	jsonLines := strings.EqualFold(filepath.Ext(replayFile), ".jsonl")
+	for {
+		sent, err := n.replayOnce(ctx, tick, jsonLines)
+		if err == context.Canceled {
+			return
+		}
+		if err != nil {
+			log.Fatalf("Node %s cannot replay '%s': %s\n", n.Name, replayFile, err.Error())
+		}
+		if !replayLoop {
+			break
+		}
+		if sent == 0 {
+			logInfo("Node %s: '%s' has no messages to replay.\n", n.Name, replayFile)
+			break
+		}
+	}
+	logInfo("Node %s: End of replay, no more messages to send.\n", n.Name)
+}
+
+// replayOnce makes one pass through the replay file, and returns the number of messages that it has sent.
+func (n *Node) replayOnce(ctx context.Context, tick <-chan time.Time, jsonLines bool) (int, error) {
+	f, err := os.Open(replayFile)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	sent := 0
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		body, err := replayBody(line, jsonLines)
+		if err != nil {
+			logError("Node %s: Skipping line %d of '%s': %s\n", n.Name, lineNo, replayFile, err.Error())
+			continue
+		}
+		if err := processing.WaitCtx(ctx); err != nil {
+			return sent, err
+		}
+		if err := n.sendOne(ctx, body); err != nil {
+			return sent, err
+		}
+		sent++
+		if tick == nil {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return sent, ctx.Err()
+		case <-tick:
+		}
+	}
+	return sent, scanner.Err()
+}
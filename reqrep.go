@@ -0,0 +1,106 @@
+package main
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/go-mangos/mangos"
+	"github.com/go-mangos/mangos/protocol/rep"
+	"github.com/go-mangos/mangos/protocol/req"
+)
+
+// The Request-Reply example consists of a REP node that listens and answers requests, and any number of REQ nodes that dial the REP node and send requests. The REQ socket takes care of matching each reply to its request.
+
+// newReqSocket creates a socket that speaks the REQ protocol. A REQ socket must alternate between sending a request and receiving its reply.
+//
+// The timeout sets the receive deadline, so a requester whose server is missing gives up after a while instead of blocking forever.
+func (n *Node) newReqSocket(timeout time.Duration) mangos.Socket {
+	socket, err := req.NewSocket()
+	if err != nil {
+		log.Fatalf("Node %s: Cannot create socket: %s\n", n.Name, err.Error())
+	}
+	n.setupSocket(socket, timeout)
+	return socket
+}
+
+// newRepSocket creates a socket that speaks the REP protocol. A REP socket must alternate between receiving a request and sending the reply.
+func (n *Node) newRepSocket(timeout time.Duration) mangos.Socket {
+	socket, err := rep.NewSocket()
+	if err != nil {
+		log.Fatalf("Node %s: Cannot create socket: %s\n", n.Name, err.Error())
+	}
+	n.setupSocket(socket, timeout)
+	return socket
+}
+
+// newCorrelationID returns a random id in the format of a UUID (version 4). Each request gets such an id, and the reply carries the same id, so the requester can tell which request a reply belongs to. A REQ socket only has one request in flight at a time, but a requester that keeps several requests in flight, for example over several sockets, needs the ids to match the replies to the requests.
+func (n *Node) newCorrelationID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		log.Fatalf("Node %s: Cannot create a correlation id: %s\n", n.Name, err.Error())
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// runRep listens on the URL and answers each request with an uppercased copy that carries the request's correlation id. It stops when no request has arrived for the duration of the receive deadline.
+func (n *Node) runRep(url string, timeout time.Duration) {
+	socket := n.newRepSocket(timeout)
+	defer socket.Close()
+	n.socket = socket
+	err := listen(socket, url)
+	if err != nil {
+		log.Fatalf("Node %s cannot listen on socket '%s': %s\n", n.Name, url, err.Error())
+	}
+	for {
+		processing.Wait()
+		request, err := n.Receive()
+		if errors.Is(err, ErrTimeout) || errors.Is(err, mangos.ErrClosed) {
+			break
+		}
+		if err != nil {
+			log.Fatalf("Node %s failed receiving a request: %s\n", n.Name, err.Error())
+		}
+		reply := n.newMessage(strings.ToUpper(request.Body))
+		reply.ID = request.ID
+		if err := n.sendMessage(reply); err != nil {
+			log.Fatalf("Node %s failed to send '%s': %s\n", n.Name, reply.Body, err.Error())
+		}
+	}
+	logInfo("Node %s: Done.\n", n.Name)
+}
+
+// runReq dials the URL, sends three requests, and prints each reply. A reply with a different correlation id than the request gets reported and skipped.
+func (n *Node) runReq(url string, timeout time.Duration) {
+	socket := n.newReqSocket(timeout)
+	defer socket.Close()
+	n.socket = socket
+	err := dial(socket, url)
+	if err != nil {
+		log.Fatalf("Node %s cannot dial on socket '%s': %s\n", n.Name, url, err.Error())
+	}
+	for i := 0; i < 3; i++ {
+		processing.Wait()
+		request := n.newMessage(fmt.Sprintf("request %d from node %s.", i, n.Name))
+		request.ID = n.newCorrelationID()
+		if err := n.sendMessage(request); err != nil {
+			log.Fatalf("Node %s failed to send '%s': %s\n", n.Name, request.Body, err.Error())
+		}
+		reply, err := n.Receive()
+		if err != nil {
+			log.Fatalf("Node %s received no reply to request %d: %s\n", n.Name, i, err.Error())
+		}
+		if reply.ID != request.ID {
+			logError("Node %s: Reply '%s' has id %s, but the request has id %s\n", n.Name, reply.Body, reply.ID, request.ID)
+			continue
+		}
+		fmt.Printf("%s (id %s)\n", reply.Body, reply.ID)
+		time.Sleep(1 * time.Second)
+	}
+	logInfo("Node %s: Done.\n", n.Name)
+}
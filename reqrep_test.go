@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCorrelationIDFormat(t *testing.T) {
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	n := newNode("req")
+	seen := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		id := n.newCorrelationID()
+		if !uuid.MatchString(id) {
+			t.Fatalf("'%s' is no version 4 UUID", id)
+		}
+		if seen[id] {
+			t.Fatalf("id '%s' came up twice", id)
+		}
+		seen[id] = true
+	}
+}
+
+// Three requesters keep one request each in flight at the same time, so the server sees them in any order. Each reply must carry the id of the request that it answers.
+func TestRepliesCarryCorrelationID(t *testing.T) {
+	url := testURL(t, "")
+	server := newNode("rep")
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		server.runRep(url, 500*time.Millisecond)
+	}()
+	defer func() { <-done }()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			n := newNode(fmt.Sprintf("req%d", i))
+			n.socket = n.newReqSocket(testTimeout)
+			defer n.socket.Close()
+			if err := dialWithRetry(n.socket, url, 20, 10*time.Millisecond); err != nil {
+				t.Error(err)
+				return
+			}
+			request := n.newMessage(fmt.Sprintf("request %d", i))
+			request.ID = n.newCorrelationID()
+			if err := n.sendMessage(request); err != nil {
+				t.Error(err)
+				return
+			}
+			reply, err := n.Receive()
+			if err != nil {
+				t.Errorf("%s got no reply: %s", n.Name, err)
+				return
+			}
+			if reply.ID != request.ID {
+				t.Errorf("%s: reply '%s' has id %s, want %s", n.Name, reply.Body, reply.ID, request.ID)
+			}
+			if reply.Body != strings.ToUpper(request.Body) {
+				t.Errorf("%s: reply '%s' does not answer '%s'", n.Name, reply.Body, request.Body)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// selfTestURL connects the two nodes of the self-test. The inproc transport needs no network and no free port.
+const selfTestURL = "inproc://selftest"
+
+// selfTestConnectTimeout is how long the self-test waits for its two nodes to connect. Over inproc, they connect right away, so running into this timeout means that something is broken.
+const selfTestConnectTimeout = 2 * time.Second
+
+// selfTest runs two PAIR nodes inside this process and lets them exchange a few messages in both directions. This checks that the program works, without two terminals, and with the options that the user has set, like `-codec`, `-compress`, or `-encrypt-key`. It doubles as a quick smoke test in CI.
+func selfTest(timeout time.Duration) error {
+	listener, dialer := newNode("selftest-listener"), newNode("selftest-dialer")
+	var err error
+	for _, n := range []*Node{listener, dialer} {
+		if n.socket, err = n.newSocket(timeout); err != nil {
+			return err
+		}
+		defer n.socket.Close()
+	}
+
+	connected := onConnect(listener.socket)
+	if err = listen(listener.socket, selfTestURL); err != nil {
+		return fmt.Errorf("cannot listen: %w", err)
+	}
+	if err = dial(dialer.socket, selfTestURL); err != nil {
+		return fmt.Errorf("cannot dial: %w", err)
+	}
+	select {
+	case <-connected:
+	case <-time.After(selfTestConnectTimeout):
+		return fmt.Errorf("no connection within %s", selfTestConnectTimeout)
+	}
+
+	for i := 0; i < 3; i++ {
+		if err = exchange(dialer, listener, fmt.Sprintf("request %d", i)); err != nil {
+			return err
+		}
+		if err = exchange(listener, dialer, fmt.Sprintf("reply %d", i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exchange sends body from one node and checks that it arrives unchanged at the other one.
+func exchange(from, to *Node, body string) error {
+	if err := from.Send(body); err != nil {
+		return fmt.Errorf("cannot send '%s': %w", body, err)
+	}
+	m, err := to.Receive()
+	if err != nil {
+		return fmt.Errorf("did not receive '%s': %w", body, err)
+	}
+	if m.Body != body {
+		return fmt.Errorf("sent '%s' but received '%s'", body, m.Body)
+	}
+	return nil
+}
@@ -0,0 +1,9 @@
+package main
+
+import "testing"
+
+func TestSelfTest(t *testing.T) {
+	if err := selfTest(testTimeout); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import "sync"
+
+// Every message carries a sequence number that its sender increments with each message (see `newMessage()`). A receiver can use these numbers to find out whether messages got lost or arrived out of order.
+//
+// PAIR over TCP delivers all messages in order, so it should never see a gap. PUB/SUB and BUS, however, drop messages when a receiver is too slow or not connected yet, and the sequence numbers make these drops visible.
+
+// sequenceTracker remembers the last sequence number it has seen from each sender.
+type sequenceTracker struct {
+	mu   sync.Mutex
+	last map[string]int
+}
+
+func newSequenceTracker() *sequenceTracker {
+	return &sequenceTracker{last: map[string]int{}}
+}
+
+// track records the sequence number seq from sender from. It returns how many messages are missing before this one, and whether this message is out of order, that is, not newer than the last one from that sender. The first message from a sender never counts as a gap, as the receiver may have started late.
+func (t *sequenceTracker) track(from string, seq int) (missing int, reordered bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	last, seen := t.last[from]
+	switch {
+	case !seen:
+	case seq <= last:
+		return 0, true
+	case seq > last+1:
+		missing = seq - last - 1
+	}
+	t.last[from] = seq
+	return missing, false
+}
+
+// checkSequence logs a warning if m does not directly follow the previous message from the same sender. `Receive()` and `receiveTopic()` call it for every message.
+//
+// The node's tracker is nil unless the node has a use for it: when a REP node serves several REQ nodes, or several PULL nodes share the work of one PUSH node, gaps are normal.
+func (n *Node) checkSequence(m Message) {
+	if n.sequences == nil {
+		return
+	}
+	missing, reordered := n.sequences.track(m.From, m.Seq)
+	if reordered {
+		logError("Node %s: Warning: message %d from %s arrived out of order or twice\n", n.Name, m.Seq, m.From)
+	}
+	if missing > 0 {
+		logError("Node %s: Warning: %d message(s) from %s missing before message %d\n", n.Name, missing, m.From, m.Seq)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"os"
+)
+
+// With `-stdin`, a PAIR node sends the lines that it reads from standard input rather than the canned messages, and prints the messages that it receives to standard output. Two nodes make a tiny chat:
+//
+//	$ ./messaging -stdin -recv-timeout 0 0 tcp://localhost:54545
+//
+// The log goes to standard error, so `2>/dev/null` leaves just the chat.
+var readStdin bool
+
+// sendStdin sends each line from standard input as a message. At the end of the input, it stops sending, but the node keeps receiving until the receive deadline passes or the user interrupts it.
+func (n *Node) sendStdin(ctx context.Context) {
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		if err := scanner.Err(); err != nil {
+			logError("Node %s cannot read standard input: %s\n", n.Name, err.Error())
+		}
+	}()
+	for {
+		var line string
+		var ok bool
+		select {
+		case <-ctx.Done():
+			return
+		case line, ok = <-lines:
+		}
+		if !ok {
+			logInfo("Node %s: End of input, no more messages to send.\n", n.Name)
+			return
+		}
+		if processing.WaitCtx(ctx) != nil {
+			return
+		}
+		err := n.sendOne(ctx, line)
+		if err == context.Canceled {
+			return
+		}
+		if err != nil {
+			log.Fatalf("Node %s failed to send '%s': %s\n", n.Name, line, err.Error())
+		}
+	}
+}
+
+// printMessage is the handler of `-stdin`. It prints the messages to standard output.
+func printMessage(m Message) {
+	fmt.Printf("%s: %s\n", m.From, m.Body)
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-mangos/mangos"
+	"github.com/go-mangos/mangos/protocol/sub"
+)
+
+// A Subscriber manages the topics of a SUB socket while it runs. A program can call `Subscribe()` and `Unsubscribe()` at any time, and gets the messages of each topic on a separate channel. `runSub()` subscribes to its topics right at the start, but a long-running program could, say, follow the topics that its users pick.
+type Subscriber struct {
+	// node owns the SUB socket. It is a node of its own, so that receiving keeps away from the socket of the node that created the Subscriber.
+	node *Node
+	mu   sync.Mutex
+	subs map[string]*subscription
+	all  []*subscription
+	done chan struct{}
+	err  error
+}
+
+// subscription is the delivery channel of one topic. done gets closed when the topic is unsubscribed.
+type subscription struct {
+	ch   chan Message
+	done chan struct{}
+}
+
+// What happens if the publisher restarts? Mangos redials on its own, but do the subscriptions survive? They do, without any help from us: a SUB socket does not send its subscriptions to the publisher. It receives every message from the connection and filters on its own end, so the subscriptions belong to the socket and not to the connection. After a reconnect, the same topics arrive on the same channels. The only catch is the receive deadline: if the publisher stays away for longer, the Subscriber stops (see `run()`).
+//
+// newSubscriber dials the publisher at url and starts receiving. It has no subscriptions yet, so it receives nothing until the first call to `Subscribe()`.
+func (n *Node) newSubscriber(url string, timeout time.Duration) (*Subscriber, error) {
+	socket, err := sub.NewSocket()
+	if err != nil {
+		return nil, err
+	}
+	n.setupSocket(socket, timeout)
+	if err = dial(socket, url); err != nil {
+		socket.Close()
+		return nil, err
+	}
+	s := &Subscriber{
+		node: newNode(n.Name),
+		subs: map[string]*subscription{},
+		done: make(chan struct{}),
+	}
+	s.node.socket = socket
+	// If n tracks sequence numbers, the Subscriber reports gaps through n's tracker.
+	s.node.sequences = n.sequences
+	go s.run()
+	return s, nil
+}
+
+// Subscribe subscribes to a topic and returns the channel that the topic's messages arrive on. Like the SUB socket itself, it treats the topic as a prefix: subscribing to "news" also delivers messages on the topic "newsflash".
+//
+// Subscribing to a topic again returns the same channel.
+func (s *Subscriber) Subscribe(topic string) (<-chan Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sub, ok := s.subs[topic]; ok {
+		return sub.ch, nil
+	}
+	err := s.node.socket.SetOption(mangos.OptionSubscribe, []byte(topic))
+	if err != nil {
+		return nil, err
+	}
+	sub := &subscription{ch: make(chan Message, 16), done: make(chan struct{})}
+	s.subs[topic] = sub
+	s.all = append(s.all, sub)
+	return sub.ch, nil
+}
+
+// Unsubscribe ends the subscription to a topic. No more messages arrive on the topic's channel; the channel gets closed when the Subscriber stops.
+func (s *Subscriber) Unsubscribe(topic string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sub, ok := s.subs[topic]
+	if !ok {
+		return nil
+	}
+	delete(s.subs, topic)
+	close(sub.done)
+	return s.node.socket.SetOption(mangos.OptionUnsubscribe, []byte(topic))
+}
+
+// Close closes the socket and waits until the Subscriber has stopped.
+func (s *Subscriber) Close() {
+	s.node.socket.Close()
+	<-s.done
+}
+
+// Err tells why the Subscriber stopped. It returns nil while the Subscriber is still running, and after `Close()`, as a closed socket is the normal way to stop.
+func (s *Subscriber) Err() error {
+	select {
+	case <-s.done:
+		return s.err
+	default:
+		return nil
+	}
+}
+
+// run receives messages and hands each one to the channels of all topics that match. It stops when receiving fails, for example because the socket got closed, or because no message has arrived for the duration of the receive deadline. It then closes all channels, so that a `range` over a channel ends.
+//
+// Only run sends on the channels, and only run closes them, so a channel never gets closed while run sends on it.
+func (s *Subscriber) run() {
+	defer func() {
+		s.mu.Lock()
+		for _, sub := range s.all {
+			close(sub.ch)
+		}
+		s.mu.Unlock()
+		close(s.done)
+	}()
+	for {
+		topic, m, err := s.node.receiveTopic()
+		if err == mangos.ErrClosed {
+			return
+		}
+		if err != nil {
+			s.err = err
+			return
+		}
+		s.mu.Lock()
+		var matched []*subscription
+		for prefix, sub := range s.subs {
+			if strings.HasPrefix(topic, prefix) {
+				matched = append(matched, sub)
+			}
+		}
+		s.mu.Unlock()
+		for _, sub := range matched {
+			select {
+			case sub.ch <- m:
+			case <-sub.done:
+			}
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// receiveFrom fails the test unless a message with the given body arrives on ch.
+func receiveFrom(t *testing.T, ch <-chan Message, want string) {
+	t.Helper()
+	select {
+	case m, ok := <-ch:
+		if !ok {
+			t.Fatalf("channel closed while waiting for '%s'", want)
+		}
+		if m.Body != want {
+			t.Fatalf("received '%s', want '%s'", m.Body, want)
+		}
+	case <-time.After(testTimeout):
+		t.Fatalf("did not receive '%s'", want)
+	}
+}
+
+func TestSubscriberUnsubscribe(t *testing.T) {
+	url := testURL(t, "")
+	p, connected := newTestPublisher(t, url)
+	defer closeNodes(p)
+	s, err := newNode("sub").newSubscriber(url, testTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	select {
+	case <-connected:
+	case <-time.After(testTimeout):
+		t.Fatal("the subscriber did not connect")
+	}
+
+	weather, err := s.Subscribe("weather")
+	if err != nil {
+		t.Fatal(err)
+	}
+	news, err := s.Subscribe("news")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.publish("weather", "sunny")
+	p.publish("news", "headline")
+	receiveFrom(t, weather, "sunny")
+	receiveFrom(t, news, "headline")
+
+	if err := s.Unsubscribe("news"); err != nil {
+		t.Fatal(err)
+	}
+	p.publish("news", "another headline")
+	p.publish("weather", "rainy")
+	receiveFrom(t, weather, "rainy")
+
+	// The messages are in order, so the news message would have arrived before "rainy". Closing the Subscriber closes the channels, and the news channel must come up empty.
+	s.Close()
+	for m := range news {
+		t.Errorf("received '%s' on an unsubscribed topic", m.Body)
+	}
+	if err := s.Err(); err != nil {
+		t.Errorf("Err() after Close(): %s", err)
+	}
+}
+
+// newTestPublisher creates a PUB node that listens on url, and returns it together with the channel that reports connecting subscribers. Like with newTestNode, the caller closes the node.
+func newTestPublisher(t *testing.T, url string) (*Node, <-chan struct{}) {
+	t.Helper()
+	p := newNode("pub")
+	p.socket = p.newPubSocket()
+	connected := onConnect(p.socket)
+	if err := listen(p.socket, url); err != nil {
+		p.socket.Close()
+		t.Fatal(err)
+	}
+	return p, connected
+}
+
+// The publisher goes away and comes back. The subscriptions belong to the SUB socket, so the Subscriber gets the same topics on the same channels after Mangos has reconnected. Like the other reconnect test, this one needs TCP (see `TestDialerReconnectsAfterListenerRestart`).
+func TestSubscriberSurvivesPublisherRestart(t *testing.T) {
+	defer func(d time.Duration) { reconnectTime = d }(reconnectTime)
+	reconnectTime = 10 * time.Millisecond
+
+	url := "tcp://" + freeAddr(t)
+	p, connected := newTestPublisher(t, url)
+	defer closeNodes(p)
+	s, err := newNode("sub").newSubscriber(url, testTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	weather, err := s.Subscribe("weather")
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-connected:
+	case <-time.After(testTimeout):
+		t.Fatal("the subscriber did not connect")
+	}
+	p.publish("news", "headline")
+	p.publish("weather", "before the restart")
+	receiveFrom(t, weather, "before the restart")
+
+	p.socket.Close()
+	p, connected = newTestPublisher(t, url)
+	defer closeNodes(p)
+	select {
+	case <-connected:
+	case <-time.After(testTimeout):
+		t.Fatal("the subscriber did not reconnect")
+	}
+	p.publish("news", "another headline")
+	p.publish("weather", "after the restart")
+	receiveFrom(t, weather, "after the restart")
+}
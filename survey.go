@@ -0,0 +1,154 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/go-mangos/mangos"
+	"github.com/go-mangos/mangos/protocol/respondent"
+	"github.com/go-mangos/mangos/protocol/surveyor"
+)
+
+// The Survey example has a surveyor that broadcasts a question to all respondents and gathers their answers. The twist is that the surveyor only waits for a limited time: whoever has not answered when the survey time is over, does not count.
+
+// surveyTime is how long the surveyor collects responses after sending a survey.
+var surveyTime = time.Second
+
+// respondentTimeout is how long the surveyor waits for the first respondent to connect.
+const respondentTimeout = 30 * time.Second
+
+// newSurveyorSocket creates a socket that speaks the SURVEYOR protocol and sets its survey time. Once the survey time has passed after a survey was sent, the socket stops accepting responses, and Recv() fails with mangos.ErrProtoState.
+//
+// There is a catch, though: a Recv() call that is already waiting does not notice the end of the survey. Hence we also set the receive deadline to the survey time, so that the last Recv() of a survey returns in time.
+func (n *Node) newSurveyorSocket() mangos.Socket {
+	socket, err := surveyor.NewSocket()
+	if err != nil {
+		log.Fatalf("Node %s: Cannot create socket: %s\n", n.Name, err.Error())
+	}
+	n.setupSocket(socket, surveyTime)
+	err = socket.SetOption(mangos.OptionSurveyTime, surveyTime)
+	if err != nil {
+		log.Fatalf("Node %s: Cannot set survey time: %s\n", n.Name, err.Error())
+	}
+	return socket
+}
+
+// newRespondentSocket creates a socket that speaks the RESPONDENT protocol. A respondent can only send a response after it has received a survey.
+func (n *Node) newRespondentSocket(timeout time.Duration) mangos.Socket {
+	socket, err := respondent.NewSocket()
+	if err != nil {
+		log.Fatalf("Node %s: Cannot create socket: %s\n", n.Name, err.Error())
+	}
+	n.setupSocket(socket, timeout)
+	return socket
+}
+
+// runSurveyor listens on the URL, waits for the first respondent (but no longer than respondentTimeout), and then runs three surveys. For each survey, it collects all responses that arrive within the survey time and reports how many respondents have replied.
+func (n *Node) runSurveyor(url string) {
+	socket := n.newSurveyorSocket()
+	defer socket.Close()
+	n.socket = socket
+	connected := onConnect(socket)
+	err := listen(socket, url)
+	if err != nil {
+		log.Fatalf("Node %s cannot listen on socket '%s': %s\n", n.Name, url, err.Error())
+	}
+	logInfo("Node %s waits for respondents\n", n.Name)
+	select {
+	case <-connected:
+	case <-time.After(respondentTimeout):
+		log.Fatalf("Node %s: No respondent connected within %s\n", n.Name, respondentTimeout)
+	}
+	for i := 0; i < 3; i++ {
+		processing.Wait()
+		n.send(fmt.Sprintf("survey %d from node %s: who is there?", i, n.Name))
+		responses, err := collectResponses(socket, surveyTime)
+		if err != nil {
+			log.Fatalf("Node %s failed receiving a response: %s\n", n.Name, err.Error())
+		}
+		result := SurveyResult{Survey: i, Responses: responses}
+		logInfo("Node %s: %s\n", n.Name, result)
+		time.Sleep(1 * time.Second)
+	}
+	logInfo("Node %s: Done.\n", n.Name)
+}
+
+// SurveyResult holds the responses to one survey.
+type SurveyResult struct {
+	Survey    int
+	Responses []string
+}
+
+// Count returns the number of respondents that replied in time.
+func (r SurveyResult) Count() int {
+	return len(r.Responses)
+}
+
+// String reports the count and the responses, like "2 respondents replied to survey 0: [respondent 17 respondent 42]".
+func (r SurveyResult) String() string {
+	return fmt.Sprintf("%d respondents replied to survey %d: %v", r.Count(), r.Survey, r.Responses)
+}
+
+// collectResponses receives the responses to the survey that was sent last, until deadline has passed, and returns their bodies in the order of arrival.
+//
+// The end of the survey shows up as an error: mangos.ErrProtoState once the socket's survey time is over, or mangos.ErrRecvTimeout if a waiting Recv() reaches the deadline. Both are the expected way for a survey to end, so collectResponses returns them as a nil error. Any other error is a real failure; collectResponses then returns the responses so far together with the error.
+//
+// Before each Recv(), the receive deadline is set to the time that is left, so that a silent respondent cannot make the surveyor wait any longer than deadline.
+func collectResponses(socket mangos.Socket, deadline time.Duration) ([]string, error) {
+	var responses []string
+	end := time.Now().Add(deadline)
+	for {
+		left := time.Until(end)
+		if left <= 0 {
+			return responses, nil
+		}
+		if err := socket.SetOption(mangos.OptionRecvDeadline, left); err != nil {
+			return responses, err
+		}
+		payload, err := socket.Recv()
+		if err == mangos.ErrProtoState || err == mangos.ErrRecvTimeout {
+			return responses, nil
+		}
+		if err != nil {
+			return responses, err
+		}
+		m, err := unpack(payload)
+		if err != nil {
+			return responses, err
+		}
+		if m.Heartbeat || m.Ack {
+			continue
+		}
+		stats.countRecv(nil)
+		responses = append(responses, m.Body)
+	}
+}
+
+// runRespondent dials the surveyor's URL and answers each survey with its id, until no survey has arrived for the duration of the receive deadline. If no id is given, the process id serves as the respondent's id.
+func (n *Node) runRespondent(url, id string, timeout time.Duration) {
+	if id == "" {
+		id = fmt.Sprint(os.Getpid())
+	}
+	socket := n.newRespondentSocket(timeout)
+	defer socket.Close()
+	n.socket = socket
+	err := dial(socket, url)
+	if err != nil {
+		log.Fatalf("Node %s cannot dial on socket '%s': %s\n", n.Name, url, err.Error())
+	}
+	for {
+		processing.Wait()
+		_, err := n.Receive()
+		if errors.Is(err, ErrTimeout) || errors.Is(err, mangos.ErrClosed) {
+			break
+		}
+		if err != nil {
+			log.Fatalf("Node %s failed receiving a survey: %s\n", n.Name, err.Error())
+		}
+		n.send("respondent " + id)
+	}
+	logInfo("Node %s: Done.\n", n.Name)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+)
+
+// Of three respondents, only two answer. The surveyor must count exactly these two, and stop waiting when the survey time is over.
+func TestSurveyCountsRespondentsThatAnswer(t *testing.T) {
+	defer func(d time.Duration) { surveyTime = d }(surveyTime)
+	surveyTime = 300 * time.Millisecond
+
+	url := testURL(t, "")
+	s := newNode("surveyor")
+	s.socket = s.newSurveyorSocket()
+	defer s.socket.Close()
+	connected := onConnect(s.socket)
+	if err := listen(s.socket, url); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		r := newNode(fmt.Sprintf("respondent%d", i))
+		r.socket = r.newRespondentSocket(testTimeout)
+		defer r.socket.Close()
+		if err := dial(r.socket, url); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case <-connected:
+		case <-time.After(testTimeout):
+			t.Fatalf("%s did not connect", r.Name)
+		}
+		answers := i < 2
+		go func() {
+			if _, err := r.Receive(); err != nil || !answers {
+				return
+			}
+			r.send(r.Name)
+		}()
+	}
+
+	s.send("who is there?")
+	start := time.Now()
+	responses, err := collectResponses(s.socket, surveyTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if took := time.Since(start); took > surveyTime+200*time.Millisecond {
+		t.Errorf("the survey took %s, but the survey time is %s", took, surveyTime)
+	}
+	sort.Strings(responses)
+	if len(responses) != 2 || responses[0] != "respondent0" || responses[1] != "respondent1" {
+		t.Errorf("got responses %v, want [respondent0 respondent1]", responses)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strings"
+
+	"github.com/go-mangos/mangos"
+)
+
+// For TCP connections over untrusted networks, nodes can use `tls+tcp://` URLs. The TLS transport needs a `*tls.Config`, and the two sides need different things in it:
+//
+// * A listening node must have a certificate and its private key (`-cert` and `-key`). Without them, listening fails.
+// * A dialing node must be able to verify the listener's certificate. For a self-signed certificate, or one from a private CA, pass the CA certificate with `-cacert`; otherwise, the system's root CAs apply. The listener's certificate must be valid for the host name or IP address in the URL.
+// * If a listening node gets `-cacert`, too, it requires the dialing nodes to present a client certificate (`-cert` and `-key`) that this CA has signed. This is called mutual TLS.
+
+// tlsConfig is the TLS configuration that nodes use for `tls+tcp://` URLs. It is nil if none of the TLS options is set.
+var tlsConfig *tls.Config
+
+// newTLSConfig builds a TLS configuration from PEM files. Any of the file names may be empty.
+func newTLSConfig(certFile, keyFile, caFile string) (*tls.Config, error) {
+	cfg := &tls.Config{}
+	if (certFile == "") != (keyFile == "") {
+		return nil, errors.New("-cert and -key must be used together")
+	}
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	if caFile != "" {
+		pem, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read CA certificate: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in '%s'", caFile)
+		}
+		// A dialer verifies the listener against RootCAs, a listener verifies dialers against ClientCAs.
+		cfg.RootCAs = pool
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return cfg, nil
+}
+
+// transportOptions returns the options that listening on or dialing the URL needs.
+//
+// Mangos does not pass `OptionTLSConfig` from the socket on to the transport, so it must go along with each listener and dialer instead. A dialer also needs to know which host name to verify, and this comes from the URL.
+func transportOptions(url string) map[string]interface{} {
+	if !strings.HasPrefix(url, "tls+tcp://") {
+		return nil
+	}
+	cfg := &tls.Config{}
+	if tlsConfig != nil {
+		cfg = tlsConfig.Clone()
+	}
+	if host, _, err := net.SplitHostPort(strings.TrimPrefix(url, "tls+tcp://")); err == nil {
+		cfg.ServerName = host
+	}
+	return map[string]interface{}{mangos.OptionTLSConfig: cfg}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeSelfSignedCert creates a certificate for 127.0.0.1 that signs itself, so it can act as its own CA, and writes it and its key as PEM files into a temporary directory. The caller calls remove to delete the directory.
+func writeSelfSignedCert(t *testing.T) (certFile, keyFile string, remove func()) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "messaging test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "messaging-tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	remove = func() { os.RemoveAll(dir) }
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		remove()
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		remove()
+		t.Fatal(err)
+	}
+	return certFile, keyFile, remove
+}
+
+// Both nodes use the same self-signed certificate, and trust it as their CA. As the listener gets -cacert, too, this is mutual TLS.
+func TestTLSSelfSignedExchange(t *testing.T) {
+	defer func(cfg *tls.Config) { tlsConfig = cfg }(tlsConfig)
+	certFile, keyFile, remove := writeSelfSignedCert(t)
+	defer remove()
+	cfg, err := newTLSConfig(certFile, keyFile, certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tlsConfig = cfg
+
+	l, d := newTestNode(t, "listener"), newTestNode(t, "dialer")
+	defer closeNodes(l, d)
+	listenAndDial(t, l, d, "tls+tcp://"+freeAddr(t))
+	if err := exchange(d, l, "secret"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Without -cacert, the dialer checks the listener's certificate against the system's root CAs, which know nothing about a self-signed certificate. The handshake fails, and no connection comes up.
+func TestTLSRejectsUnknownCert(t *testing.T) {
+	defer func(cfg *tls.Config) { tlsConfig = cfg }(tlsConfig)
+	certFile, keyFile, remove := writeSelfSignedCert(t)
+	defer remove()
+	cfg, err := newTLSConfig(certFile, keyFile, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tlsConfig = cfg
+
+	l, d := newTestNode(t, "listener"), newTestNode(t, "dialer")
+	defer closeNodes(l, d)
+	url := "tls+tcp://" + freeAddr(t)
+	connected := onConnect(l.socket)
+	if err := listen(l.socket, url); err != nil {
+		t.Fatal(err)
+	}
+	if err := dial(d.socket, url); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-connected:
+		t.Fatal("the nodes connected although the dialer cannot verify the certificate")
+	case <-time.After(300 * time.Millisecond):
+	}
+}
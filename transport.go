@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-mangos/mangos"
+	"github.com/go-mangos/mangos/transport/inproc"
+	"github.com/go-mangos/mangos/transport/ipc"
+	"github.com/go-mangos/mangos/transport/tcp"
+	"github.com/go-mangos/mangos/transport/tlstcp"
+	"github.com/go-mangos/mangos/transport/ws"
+)
+
+// transports maps each URL scheme to the constructor of the transport that handles it.
+//
+// The inproc transport connects sockets within the same process, without any network involved. This is of no use for two separate node processes, but it comes in handy for running several nodes inside one process, for example in tests.
+var transports = map[string]func() mangos.Transport{
+	"tcp":     tcp.NewTransport,
+	"tls+tcp": tlstcp.NewTransport,
+	"ipc":     ipc.NewTransport,
+	"ws":      ws.NewTransport,
+	"inproc":  inproc.NewTransport,
+}
+
+// RegisterTransport makes a transport available for URLs with the given scheme, or replaces the transport for a scheme that is already known. This way, an experimental transport can be plugged in without touching the code that creates the sockets. Register transports before creating any node, as the table is not safe for concurrent use.
+func RegisterTransport(scheme string, ctor func() mangos.Transport) {
+	transports[scheme] = ctor
+}
+
+// urlScheme returns the part of the URL before "://".
+func urlScheme(url string) string {
+	if i := strings.Index(url, "://"); i >= 0 {
+		return url[:i]
+	}
+	return url
+}
+
+// addTransportForURL adds the transport that the URL's scheme asks for, and nothing else. An unknown scheme, like a mistyped `tpc://`, yields an error that names the scheme, which is clearer than the generic "invalid or unsupported transport" that Mangos would report later.
+func addTransportForURL(socket mangos.Socket, url string) error {
+	scheme := urlScheme(url)
+	ctor, ok := transports[scheme]
+	if !ok {
+		return fmt.Errorf("unknown transport scheme '%s' in URL '%s'", scheme, url)
+	}
+	socket.AddTransport(ctor())
+	return nil
+}
+
+// dial adds the transport for the URL and dials it, passing along the options that the transport needs (see `tls.go`).
+func dial(socket mangos.Socket, url string) error {
+	err := addTransportForURL(socket, url)
+	if err != nil {
+		return err
+	}
+	return socket.DialOptions(url, transportOptions(url))
+}
+
+// Dialing can fail right at the start, for example when the peer's host name does not resolve yet because its container is still starting. Once a dial has succeeded, Mangos takes care of connecting and reconnecting on its own (see `setupSocket()`).
+var (
+	dialAttempts   = 5
+	dialBackoff    = 100 * time.Millisecond
+	maxDialBackoff = 5 * time.Second
+)
+
+// dialWithRetry dials the URL up to attempts times. Between two attempts, it sleeps for base at first, and then twice as long after each failed attempt, up to maxDialBackoff. If all attempts fail, it returns the last error, in the class ErrNoConnection.
+//
+// An unknown transport scheme does not get better by waiting, so dialWithRetry does not retry in this case.
+func dialWithRetry(socket mangos.Socket, url string, attempts int, base time.Duration) error {
+	err := addTransportForURL(socket, url)
+	if err != nil {
+		return err
+	}
+	delay := base
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			logError("Cannot dial '%s': %s\nRetrying in %s\n", url, err.Error(), delay)
+			time.Sleep(delay)
+			delay *= 2
+			if delay > maxDialBackoff {
+				delay = maxDialBackoff
+			}
+		}
+		err = socket.DialOptions(url, transportOptions(url))
+		if err == nil {
+			return nil
+		}
+	}
+	return &Error{Class: ErrNoConnection, Err: err}
+}
@@ -0,0 +1,15 @@
+package main
+
+import "testing"
+
+// Two nodes in the same process, connected over inproc, talk in both directions. No port gets bound.
+func TestInprocExchange(t *testing.T) {
+	l, d := newTestPair(t)
+	defer closeNodes(l, d)
+	if err := exchange(d, l, "ping"); err != nil {
+		t.Fatal(err)
+	}
+	if err := exchange(l, d, "pong"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-mangos/mangos"
+	"github.com/go-mangos/mangos/protocol/star"
+)
+
+// newStarLine connects four STAR nodes in a line, A - B - C - D. A STAR node forwards every message it receives to all its other peers, so a message from A travels to D in three hops. The caller closes the nodes.
+func newStarLine(t *testing.T) []*Node {
+	t.Helper()
+	var line []*Node
+	for _, name := range []string{"A", "B", "C", "D"} {
+		n := newNode(name)
+		socket, err := star.NewSocket()
+		if err != nil {
+			closeNodes(line...)
+			t.Fatal(err)
+		}
+		n.setupSocket(socket, testTimeout)
+		n.socket = socket
+		line = append(line, n)
+	}
+	for i := 1; i < len(line); i++ {
+		listenAndDial(t, line[i], line[i-1], testURL(t, fmt.Sprintf("-%s", line[i].Name)))
+	}
+	return line
+}
+
+func TestTTLLimitsHops(t *testing.T) {
+	defer func(hops int) { ttl = hops }(ttl)
+	tests := []struct {
+		ttl     int
+		reached string // the last node that gets the message
+	}{
+		{1, "B"},
+		{2, "C"},
+		{0, "D"}, // The Mangos default of 8 hops is plenty for this line.
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("ttl=%d", tt.ttl), func(t *testing.T) {
+			ttl = tt.ttl
+			line := newStarLine(t)
+			defer closeNodes(line...)
+			if err := line[0].Send("hop"); err != nil {
+				t.Fatal(err)
+			}
+			beyond := false
+			for _, n := range line[1:] {
+				if beyond {
+					n.socket.SetOption(mangos.OptionRecvDeadline, 200*time.Millisecond)
+					if m, err := n.Receive(); err == nil {
+						t.Errorf("node %s received '%s' beyond the TTL", n.Name, m.Body)
+					}
+					continue
+				}
+				expectBody(t, n, "hop")
+				beyond = n.Name == tt.reached
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// With `-validate`, the node checks the URLs it got, reports any problems, and exits, without opening a single socket. A typo like `tcp://localhost:5555O` is much easier to spot in a validation message than in the bind error that it causes later.
+//
+// validateURL returns an error if the URL cannot work, and warnings for URLs that may work but probably do not do what the user wants.
+func validateURL(url string) (warnings []string, err error) {
+	parts := strings.SplitN(url, "://", 2)
+	if len(parts) != 2 {
+		return nil, errors.New("missing scheme, e.g. tcp://")
+	}
+	scheme, addr := parts[0], parts[1]
+	switch scheme {
+	case "tcp", "tls+tcp", "ws":
+		if scheme == "ws" {
+			addr = strings.SplitN(addr, "/", 2)[0]
+		}
+		_, portStr, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		port, err := strconv.Atoi(portStr)
+		if err != nil || port < 0 || port > 65535 {
+			return nil, fmt.Errorf("invalid port '%s': must be a number up to 65535", portStr)
+		}
+		// Port 0 lets the system pick a free port, but then the peer does not know which one.
+		if port == 0 {
+			warnings = append(warnings, "port 0 picks a random port that no peer can know")
+		}
+		// The URL does not tell whether the node listens or dials, and dialing any port is fine. So the port ranges only get a warning.
+		switch {
+		case port > 0 && port < 1024:
+			warnings = append(warnings, fmt.Sprintf("port %d is a privileged port; listening on it requires special permissions. Consider a port from the dynamic range 49152-65535", port))
+		case port >= 1024 && port < 49152:
+			warnings = append(warnings, fmt.Sprintf("port %d is a registered port that another service may use. Consider a port from the dynamic range 49152-65535", port))
+		}
+		if scheme == "tls+tcp" && tlsConfig == nil {
+			warnings = append(warnings, "tls+tcp needs -cert and -key for listening, or -cacert for dialing a peer with a self-signed certificate")
+		}
+	case "ipc":
+		if addr == "" {
+			return nil, errors.New("missing path of the socket file")
+		}
+		if _, err := os.Stat(filepath.Dir(addr)); err != nil {
+			warnings = append(warnings, fmt.Sprintf("directory '%s' does not exist", filepath.Dir(addr)))
+		}
+	case "inproc":
+		if addr == "" {
+			return nil, errors.New("missing name")
+		}
+		warnings = append(warnings, "inproc only connects sockets within the same process")
+	default:
+		// A transport added with RegisterTransport is fine, but we know nothing about its addresses.
+		if _, ok := transports[scheme]; !ok {
+			return nil, fmt.Errorf("unknown transport scheme '%s'", scheme)
+		}
+	}
+	return warnings, nil
+}
+
+// validateURLs validates all URLs and logs the findings. It returns false if any URL cannot work.
+func validateURLs(urls []string) bool {
+	ok := true
+	for _, url := range urls {
+		warnings, err := validateURL(url)
+		if err != nil {
+			logError("URL '%s': Error: %s\n", url, err.Error())
+			ok = false
+			continue
+		}
+		for _, w := range warnings {
+			logError("URL '%s': Warning: %s\n", url, w)
+		}
+		if len(warnings) == 0 {
+			logInfo("URL '%s': OK\n", url)
+		}
+	}
+	return ok
+}
+
+// commandURLs returns the URLs among the arguments of a command. Which arguments are URLs depends on the command: a subscriber, for example, gets topics after its URL, but a bus node gets the URLs of its peers.
+func commandURLs(command string, args []string) []string {
+	switch command {
+	case "bench":
+		return []string{benchURL}
+	case "sub", "respondent", "pub", "req", "rep", "push", "pull", "surveyor":
+		return args[:1]
+	}
+	return args
+}
@@ -0,0 +1,32 @@
+package main
+
+import "testing"
+
+func TestValidateURL(t *testing.T) {
+	tests := []struct {
+		url          string
+		wantErr      bool
+		wantWarnings int
+	}{
+		{"tcp://localhost:54545", false, 0},
+		{"tcp://localhost:5454O", true, 0},
+		{"tcp://localhost:65536", true, 0},
+		{"localhost:54545", true, 0},
+		{"ws://localhost:54545/chat", false, 0},
+		{"foo://localhost:54545", true, 0},
+		{"inproc://", true, 0},
+		// Ports outside the dynamic range may work, so they only get a warning.
+		{"tcp://localhost:80", false, 1},
+		{"tcp://localhost:5555", false, 1},
+		{"tcp://localhost:0", false, 1},
+	}
+	for _, tt := range tests {
+		warnings, err := validateURL(tt.url)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("validateURL(%s): got error %v, want error: %t", tt.url, err, tt.wantErr)
+		}
+		if len(warnings) != tt.wantWarnings {
+			t.Errorf("validateURL(%s): got warnings %q, want %d", tt.url, warnings, tt.wantWarnings)
+		}
+	}
+}
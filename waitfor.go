@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// waitInterval is the time between the first two connection attempts of waitFor. After each failed attempt, waitFor waits twice as long, up to maxWaitInterval, so that a dependency that takes a while to start does not get flooded with probes.
+const (
+	waitInterval    = 100 * time.Millisecond
+	maxWaitInterval = 5 * time.Second
+)
+
+// waitFor blocks until the dependency at url accepts connections, or returns an error once the timeout has passed. The last pause never reaches past the timeout.
+//
+// It only checks whether something listens at the transport level; it does not care which protocol the dependency speaks. This way the probe does not leave a half-open connection in the dependency's socket.
+func waitFor(url string, timeout time.Duration) error {
+	network, addr, err := transportAddr(url)
+	if err != nil {
+		return err
+	}
+	logInfo("Waiting for '%s'\n", url)
+	deadline := time.Now().Add(timeout)
+	delay := waitInterval
+	for {
+		conn, err := net.DialTimeout(network, addr, maxWaitInterval)
+		if err == nil {
+			conn.Close()
+			return nil
+		}
+		left := time.Until(deadline)
+		if left <= 0 {
+			return fmt.Errorf("'%s' not reachable after %s: %s", url, timeout, err.Error())
+		}
+		if delay > left {
+			delay = left
+		}
+		time.Sleep(delay)
+		delay *= 2
+		if delay > maxWaitInterval {
+			delay = maxWaitInterval
+		}
+	}
+}
+
+// transportAddr translates a mangos URL into a network and an address that net.Dial understands.
+func transportAddr(url string) (network, addr string, err error) {
+	parts := strings.SplitN(url, "://", 2)
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("invalid URL '%s'", url)
+	}
+	scheme, addr := parts[0], parts[1]
+	switch scheme {
+	case "tcp", "tls+tcp":
+		return "tcp", addr, nil
+	case "ipc":
+		return "unix", addr, nil
+	case "ws", "wss":
+		// Strip the path from host:port/path.
+		return "tcp", strings.SplitN(addr, "/", 2)[0], nil
+	}
+	return "", "", fmt.Errorf("cannot probe URL '%s': unsupported scheme '%s'", url, scheme)
+}
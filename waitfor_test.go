@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestWaitForReachable(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if err := waitFor("tcp://"+l.Addr().String(), time.Second); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWaitForGivesUpInTime(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	start := time.Now()
+	if err := waitFor("tcp://"+addr, 500*time.Millisecond); err == nil {
+		t.Fatal("waitFor succeeded without a listener")
+	}
+	// With the backoff, the pauses are 100, 200, and 200 ms (the rest of the timeout).
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("waitFor took %s, want about 500ms", elapsed)
+	}
+}
+
+func TestWaitForLateDependency(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			return
+		}
+		time.Sleep(2 * time.Second)
+		l.Close()
+	}()
+	if err := waitFor("tcp://"+addr, 2*time.Second); err != nil {
+		t.Fatal(err)
+	}
+}
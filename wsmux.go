@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/go-mangos/mangos"
+	"github.com/go-mangos/mangos/transport/ws"
+)
+
+// A WebSocket URL has a path, so one HTTP server could offer several services on the same port, say `ws://localhost:54545/serviceA` and `ws://localhost:54545/serviceB`, each one a socket of its own. The path then routes each peer to the right socket. This is handy behind a firewall or a reverse proxy that lets just one port through.
+//
+// The ws transport of Mangos does not make this easy, though. Each listener opens its own TCP port, so the second socket that listens on the same host and port fails with "address already in use". There is a way around it: a listening ws socket hands out its HTTP request multiplexer (`ws.OptionWebSocketMux`), and any other ws listener hands out itself as an HTTP handler (`ws.OptionWebSocketHandler`). Mounting the handler of the second socket at its path on the multiplexer of the first socket routes the connections of that path to the second socket.
+//
+// There is a catch: the second socket only accepts the connections that its handler gets if it listens itself, and listening opens a TCP port. So the other sockets listen on a spare port that the operating system picks on the loopback interface, and which nobody uses. Another limitation is that the first socket owns the HTTP server: once it gets closed, the other paths are gone, too.
+//
+// The `wsmux` command shows how this works. It listens on all its URLs, and answers each message with the path that it has arrived on. Two PAIR nodes that dial different paths get different answers, which they log with `-v`:
+//
+//	./messaging wsmux ws://localhost:54545/serviceA ws://localhost:54545/serviceB
+//	./messaging -v 0 ws://localhost:54545/serviceA
+//	./messaging -v 1 ws://localhost:54545/serviceB
+
+// listenWSPaths lets each socket listen on the URL with the same index. All URLs must be ws URLs with the same host and port, and different paths.
+func listenWSPaths(sockets []mangos.Socket, urls []string) error {
+	var host string
+	paths := map[string]bool{}
+	for _, u := range urls {
+		parsed, err := url.Parse(u)
+		if err != nil {
+			return err
+		}
+		if parsed.Scheme != "ws" {
+			return fmt.Errorf("'%s' is no ws:// URL", u)
+		}
+		if host != "" && parsed.Host != host {
+			return fmt.Errorf("'%s' is not on %s", u, host)
+		}
+		host = parsed.Host
+		if parsed.Path == "" || paths[parsed.Path] {
+			return fmt.Errorf("'%s' needs a path of its own", u)
+		}
+		paths[parsed.Path] = true
+	}
+	for i, socket := range sockets {
+		if err := addTransportForURL(socket, urls[i]); err != nil {
+			return err
+		}
+	}
+	first, err := sockets[0].NewListener(urls[0], nil)
+	if err != nil {
+		return err
+	}
+	mux, err := first.GetOption(ws.OptionWebSocketMux)
+	if err != nil {
+		return err
+	}
+	for i, socket := range sockets[1:] {
+		path, _ := url.Parse(urls[i+1])
+		l, err := socket.NewListener("ws://127.0.0.1:0"+path.Path, nil)
+		if err != nil {
+			return err
+		}
+		handler, err := l.GetOption(ws.OptionWebSocketHandler)
+		if err != nil {
+			return err
+		}
+		mux.(*http.ServeMux).Handle(path.Path, handler.(http.Handler))
+		if err := l.Listen(); err != nil {
+			return err
+		}
+	}
+	return first.Listen()
+}
+
+// runWSMux listens on the URLs with one PAIR socket each, and answers every message on the socket that it came in on, until no message has arrived for the duration of the receive deadline.
+func (n *Node) runWSMux(urls []string, timeout time.Duration) {
+	nodes := make([]*Node, len(urls))
+	sockets := make([]mangos.Socket, len(urls))
+	for i, u := range urls {
+		// Each path gets a node of its own, named after the path, so that the log tells where a message got routed to.
+		path, _ := url.Parse(u)
+		nodes[i] = newNode(n.Name + path.Path)
+		socket, err := nodes[i].newSocket(timeout)
+		if err != nil {
+			log.Fatalf("Node %s: %s\n", nodes[i].Name, err.Error())
+		}
+		defer socket.Close()
+		nodes[i].socket = socket
+		sockets[i] = socket
+	}
+	if err := listenWSPaths(sockets, urls); err != nil {
+		log.Fatalf("Node %s cannot listen on the URLs: %s\n", n.Name, err.Error())
+	}
+	var wg sync.WaitGroup
+	for _, node := range nodes {
+		wg.Add(1)
+		go func(node *Node) {
+			defer wg.Done()
+			node.answer()
+		}(node)
+	}
+	wg.Wait()
+	logInfo("Node %s: Done.\n", n.Name)
+}
+
+// answer replies to each message with the name of the node, which ends in the path.
+func (n *Node) answer() {
+	for {
+		processing.Wait()
+		m, err := n.Receive()
+		if err != nil {
+			return
+		}
+		if err := n.Send(fmt.Sprintf("%s got '%s'", n.Name, m.Body)); err != nil {
+			logError("Node %s failed to answer: %s\n", n.Name, err.Error())
+			return
+		}
+	}
+}
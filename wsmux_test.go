@@ -0,0 +1,53 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-mangos/mangos"
+)
+
+// Two sockets listen on different paths of the same WebSocket port. Each message must reach the socket of the path that its sender has dialed.
+func TestWSMuxRoutesByPath(t *testing.T) {
+	base := "ws://" + freeAddr(t)
+	urls := []string{base + "/serviceA", base + "/serviceB"}
+	var services, clients []*Node
+	var sockets []mangos.Socket
+	for range urls {
+		s, c := newTestNode(t, "service"), newTestNode(t, "client")
+		defer closeNodes(s, c)
+		services = append(services, s)
+		sockets = append(sockets, s.socket)
+		clients = append(clients, c)
+	}
+	if err := listenWSPaths(sockets, urls); err != nil {
+		t.Fatal(err)
+	}
+	for i, url := range urls {
+		if err := dialWithRetry(clients[i].socket, url, 20, 10*time.Millisecond); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, url := range urls {
+		if err := exchange(clients[i], services[i], "for "+url); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestListenWSPathsRejectsBadURLs(t *testing.T) {
+	tests := [][]string{
+		{"ws://127.0.0.1:54545/a", "tcp://127.0.0.1:54545"},
+		{"ws://127.0.0.1:54545/a", "ws://127.0.0.1:54546/b"},
+		{"ws://127.0.0.1:54545/a", "ws://127.0.0.1:54545/a"},
+		{"ws://127.0.0.1:54545/a", "ws://127.0.0.1:54545"},
+	}
+	for _, urls := range tests {
+		a, b := newTestNode(t, "a"), newTestNode(t, "b")
+		defer closeNodes(a, b)
+		sockets := []mangos.Socket{a.socket, b.socket}
+		if err := listenWSPaths(sockets, urls); err == nil {
+			t.Errorf("listenWSPaths accepted %q", urls)
+		}
+	}
+}